@@ -0,0 +1,157 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+)
+
+// Decoder reads tabular records. The first record returned by Read is the
+// header row.
+type Decoder interface {
+	Read() ([]string, error)
+	// Line returns the line (or sheet row) of the record last returned by
+	// Read.
+	Line() int
+}
+
+// Encoder writes tabular records. The first record written is the header
+// row. Flush must be called once all records are written.
+type Encoder interface {
+	Write(record []string) error
+	Flush() error
+}
+
+type Options struct {
+	// Delimiter separates fields in csv. Zero means ','.
+	Delimiter rune
+	// Quote encloses csv fields. Zero means '"'.
+	Quote byte
+	// BOM writes a UTF-8 byte order mark before csv output.
+	BOM bool
+	// Keys is the header of formats whose records name their fields, such
+	// as NDJSON. Keys are matched ignoring case and surrounding space. When
+	// empty the keys of the first record are the header.
+	Keys []string
+}
+
+type Format struct {
+	Name        string
+	ContentType string
+	Extension   string
+	NewDecoder  func(r io.Reader, options *Options) (Decoder, error)
+	NewEncoder  func(w io.Writer, options *Options) (Encoder, error)
+}
+
+var ErrUnknownFormat = errors.New("unknown format")
+
+// RecordError reports a record that could not be decoded. Reading can go on
+// with the next record.
+type RecordError struct {
+	Line int
+	Err  error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	CSV = &Format{
+		Name:        "csv",
+		ContentType: "text/csv",
+		Extension:   ".csv",
+		NewDecoder:  newCsvDecoder,
+		NewEncoder:  newCsvEncoder,
+	}
+	TSV = &Format{
+		Name:        "tsv",
+		ContentType: "text/tab-separated-values",
+		Extension:   ".tsv",
+		NewDecoder:  newTsvDecoder,
+		NewEncoder:  newTsvEncoder,
+	}
+	NDJSON = &Format{
+		Name:        "ndjson",
+		ContentType: "application/x-ndjson",
+		Extension:   ".ndjson",
+		NewDecoder:  newNdjsonDecoder,
+		NewEncoder:  newNdjsonEncoder,
+	}
+	XLSX = &Format{
+		Name:        "xlsx",
+		ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		Extension:   ".xlsx",
+		NewDecoder:  newXlsxDecoder,
+		NewEncoder:  newXlsxEncoder,
+	}
+)
+
+var formats = []*Format{CSV, TSV, NDJSON, XLSX}
+
+var aliases = map[string]*Format{
+	"application/csv":        CSV,
+	"text/tsv":               TSV,
+	".tab":                   TSV,
+	"application/jsonl":      NDJSON,
+	"application/json-lines": NDJSON,
+	"application/jsonlines":  NDJSON,
+	".jsonl":                 NDJSON,
+	"jsonl":                  NDJSON,
+}
+
+// Lookup finds a format by name, content type or file extension.
+func Lookup(key string) (*Format, error) {
+	key = strings.ToLower(strings.TrimSpace(key))
+	for _, format := range formats {
+		if key == format.Name || key == format.ContentType || key == format.Extension {
+			return format, nil
+		}
+	}
+	if format, ok := aliases[key]; ok {
+		return format, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, key)
+}
+
+// Detect picks the format of an upload from its content type, falling back
+// to the file extension and then to csv. Generic types such as
+// application/octet-stream or text/plain fall through to the extension.
+func Detect(contentType string, fileName string) *Format {
+	mediaType, _, errParse := mime.ParseMediaType(contentType)
+	if errParse == nil {
+		format, err := Lookup(mediaType)
+		if err == nil {
+			return format
+		}
+	}
+
+	format, err := Lookup(filepath.Ext(fileName))
+	if err == nil {
+		return format
+	}
+	return CSV
+}
+
+// Negotiate picks the first format named in an Accept header, or nil when
+// the header allows anything.
+func Negotiate(accept string) *Format {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, errParse := mime.ParseMediaType(strings.TrimSpace(part))
+		if errParse != nil {
+			continue
+		}
+		format, err := Lookup(mediaType)
+		if err == nil {
+			return format
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+)
+
+var bom = []byte{0xef, 0xbb, 0xbf}
+
+type csvDecoder struct {
+	reader *csv.Reader
+	quote  byte
+}
+
+func newCsvDecoder(r io.Reader, options *Options) (Decoder, error) {
+	buffered := bufio.NewReader(r)
+	head, _ := buffered.Peek(len(bom))
+	if bytes.Equal(head, bom) {
+		buffered.Discard(len(bom))
+	}
+
+	quote := options.quote()
+	var src io.Reader = buffered
+	if quote != '"' {
+		src = &swapReader{r: buffered, a: quote, b: '"'}
+	}
+
+	reader := csv.NewReader(src)
+	reader.Comma = options.delimiter(',')
+	// jumlah kolom dicek per row, bukan oleh csv.Reader
+	reader.FieldsPerRecord = -1
+	return &csvDecoder{reader: reader, quote: quote}, nil
+}
+
+func newTsvDecoder(r io.Reader, options *Options) (Decoder, error) {
+	tsvOptions := *options
+	tsvOptions.Delimiter = '\t'
+	decoder, err := newCsvDecoder(r, &tsvOptions)
+	if err != nil {
+		return nil, err
+	}
+	// tsv biasanya tidak memakai quote, jadi quote di tengah field dibiarkan
+	decoder.(*csvDecoder).reader.LazyQuotes = true
+	return decoder, nil
+}
+
+func (d *csvDecoder) Read() ([]string, error) {
+	record, err := d.reader.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, &RecordError{Line: parseErr.StartLine, Err: parseErr.Err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if d.quote != '"' {
+		for idx := range record {
+			record[idx] = swap(record[idx], d.quote, '"')
+		}
+	}
+	return record, nil
+}
+
+func (d *csvDecoder) Line() int {
+	line, _ := d.reader.FieldPos(0)
+	return line
+}
+
+type csvEncoder struct {
+	writer *csv.Writer
+	out    *swapWriter
+	quote  byte
+}
+
+func newCsvEncoder(w io.Writer, options *Options) (Encoder, error) {
+	if options.BOM {
+		_, err := w.Write(bom)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	encoder := &csvEncoder{quote: options.quote()}
+	if encoder.quote != '"' {
+		encoder.out = &swapWriter{w: w, a: encoder.quote, b: '"'}
+		w = encoder.out
+	}
+
+	encoder.writer = csv.NewWriter(w)
+	encoder.writer.Comma = options.delimiter(',')
+	return encoder, nil
+}
+
+func newTsvEncoder(w io.Writer, options *Options) (Encoder, error) {
+	tsvOptions := *options
+	tsvOptions.Delimiter = '\t'
+	return newCsvEncoder(w, &tsvOptions)
+}
+
+func (e *csvEncoder) Write(record []string) error {
+	if e.quote != '"' {
+		swapped := make([]string, len(record))
+		for idx, value := range record {
+			swapped[idx] = swap(value, e.quote, '"')
+		}
+		record = swapped
+	}
+	return e.writer.Write(record)
+}
+
+func (e *csvEncoder) Flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (o *Options) delimiter(fallback rune) rune {
+	if o.Delimiter == 0 {
+		return fallback
+	}
+	return o.Delimiter
+}
+
+func (o *Options) quote() byte {
+	if o.Quote == 0 {
+		return '"'
+	}
+	return o.Quote
+}
+
+// encoding/csv only knows '"' as quote character. Another quote character
+// is supported by exchanging the two bytes on the way in and out, which
+// leaves the data itself unchanged.
+
+func swap(value string, a byte, b byte) string {
+	buf := []byte(value)
+	swapBytes(buf, a, b)
+	return string(buf)
+}
+
+func swapBytes(buf []byte, a byte, b byte) {
+	for idx, c := range buf {
+		switch c {
+		case a:
+			buf[idx] = b
+		case b:
+			buf[idx] = a
+		}
+	}
+}
+
+type swapReader struct {
+	r io.Reader
+	a byte
+	b byte
+}
+
+func (s *swapReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	swapBytes(p[:n], s.a, s.b)
+	return n, err
+}
+
+type swapWriter struct {
+	w io.Writer
+	a byte
+	b byte
+}
+
+func (s *swapWriter) Write(p []byte) (int, error) {
+	buf := make([]byte, len(p))
+	copy(buf, p)
+	swapBytes(buf, s.a, s.b)
+	return s.w.Write(buf)
+}
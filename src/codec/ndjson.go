@@ -0,0 +1,184 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ndjsonDecoder reads one JSON object per line. The header is Options.Keys,
+// or else the keys of the first object. Objects may omit keys of the header
+// but not add others.
+type ndjsonDecoder struct {
+	scanner *bufio.Scanner
+	header  []string
+	index   map[string]int
+	// sent tells whether the header was returned by Read.
+	sent    bool
+	pending []string
+	line    int
+}
+
+func newNdjsonDecoder(r io.Reader, options *Options) (Decoder, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	d := &ndjsonDecoder{scanner: scanner}
+	if len(options.Keys) > 0 {
+		d.setHeader(options.Keys)
+	}
+	return d, nil
+}
+
+func (d *ndjsonDecoder) setHeader(keys []string) {
+	d.header = keys
+	d.index = map[string]int{}
+	for idx, key := range keys {
+		d.index[ndjsonKey(key)] = idx
+	}
+}
+
+func ndjsonKey(key string) string {
+	return strings.ToLower(strings.TrimSpace(key))
+}
+
+func (d *ndjsonDecoder) Read() ([]string, error) {
+	if d.pending != nil {
+		record := d.pending
+		d.pending = nil
+		return record, nil
+	}
+	if d.header != nil && !d.sent {
+		d.sent = true
+		return d.header, nil
+	}
+
+	raw, errNext := d.next()
+	if errNext != nil {
+		return nil, errNext
+	}
+
+	keys, values, err := decodeObject(raw)
+	if err != nil {
+		return nil, &RecordError{Line: d.line, Err: err}
+	}
+	if d.header == nil {
+		d.setHeader(keys)
+		d.sent = true
+		d.pending = values
+		return d.header, nil
+	}
+
+	record := make([]string, len(d.header))
+	for idx, key := range keys {
+		pos, ok := d.index[ndjsonKey(key)]
+		if !ok {
+			return nil, &RecordError{Line: d.line, Err: fmt.Errorf("unexpected key %q", key)}
+		}
+		record[pos] = values[idx]
+	}
+	return record, nil
+}
+
+func (d *ndjsonDecoder) Line() int {
+	return d.line
+}
+
+func (d *ndjsonDecoder) next() ([]byte, error) {
+	for d.scanner.Scan() {
+		d.line++
+		raw := bytes.TrimSpace(d.scanner.Bytes())
+		if len(raw) > 0 {
+			return raw, nil
+		}
+	}
+	if err := d.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// decodeObject returns the keys of a flat JSON object in document order
+// together with their values as strings.
+func decodeObject(raw []byte) ([]string, []string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, nil, fmt.Errorf("expected a JSON object")
+	}
+
+	var keys, values []string
+	for decoder.More() {
+		keyToken, errKey := decoder.Token()
+		if errKey != nil {
+			return nil, nil, errKey
+		}
+		var value interface{}
+		errValue := decoder.Decode(&value)
+		if errValue != nil {
+			return nil, nil, errValue
+		}
+
+		switch v := value.(type) {
+		case nil:
+			values = append(values, "")
+		case string:
+			values = append(values, v)
+		case json.Number:
+			values = append(values, v.String())
+		case bool:
+			values = append(values, strconv.FormatBool(v))
+		default:
+			return nil, nil, fmt.Errorf("key %q: nested values are not supported", keyToken)
+		}
+		keys = append(keys, keyToken.(string))
+	}
+	return keys, values, nil
+}
+
+type ndjsonEncoder struct {
+	writer *bufio.Writer
+	header []string
+}
+
+func newNdjsonEncoder(w io.Writer, options *Options) (Encoder, error) {
+	return &ndjsonEncoder{writer: bufio.NewWriter(w)}, nil
+}
+
+func (e *ndjsonEncoder) Write(record []string) error {
+	if e.header == nil {
+		e.header = record
+		return nil
+	}
+
+	e.writer.WriteByte('{')
+	for idx, key := range e.header {
+		if idx > 0 {
+			e.writer.WriteByte(',')
+		}
+		encodedKey, _ := json.Marshal(key)
+		e.writer.Write(encodedKey)
+		e.writer.WriteByte(':')
+
+		value := ""
+		if idx < len(record) {
+			value = record[idx]
+		}
+		encodedValue, _ := json.Marshal(value)
+		e.writer.Write(encodedValue)
+	}
+	e.writer.WriteByte('}')
+	return e.writer.WriteByte('\n')
+}
+
+func (e *ndjsonEncoder) Flush() error {
+	return e.writer.Flush()
+}
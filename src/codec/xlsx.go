@@ -0,0 +1,120 @@
+package codec
+
+import (
+	"errors"
+	"io"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// xlsxDecoder reads the first sheet of a workbook. The workbook is a zip
+// archive, so excelize needs the whole upload before rows can be read.
+type xlsxDecoder struct {
+	file   *excelize.File
+	rows   *excelize.Rows
+	width  int
+	line   int
+	closed bool
+}
+
+func newXlsxDecoder(r io.Reader, options *Options) (Decoder, error) {
+	file, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sheets := file.GetSheetList()
+	if len(sheets) == 0 {
+		file.Close()
+		return nil, errors.New("workbook has no sheets")
+	}
+
+	rows, errRows := file.Rows(sheets[0])
+	if errRows != nil {
+		file.Close()
+		return nil, errRows
+	}
+
+	return &xlsxDecoder{file: file, rows: rows}, nil
+}
+
+func (d *xlsxDecoder) Read() ([]string, error) {
+	if d.closed || !d.rows.Next() {
+		return nil, d.close()
+	}
+	d.line++
+
+	record, err := d.rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	// excelize memotong cell kosong di akhir row
+	if d.width == 0 {
+		d.width = len(record)
+	}
+	for len(record) < d.width {
+		record = append(record, "")
+	}
+	return record, nil
+}
+
+func (d *xlsxDecoder) Line() int {
+	return d.line
+}
+
+func (d *xlsxDecoder) close() error {
+	if d.closed {
+		return io.EOF
+	}
+	d.closed = true
+
+	errRows := d.rows.Error()
+	d.rows.Close()
+	d.file.Close()
+	if errRows != nil {
+		return errRows
+	}
+	return io.EOF
+}
+
+type xlsxEncoder struct {
+	w      io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+func newXlsxEncoder(w io.Writer, options *Options) (Encoder, error) {
+	file := excelize.NewFile()
+	stream, err := file.NewStreamWriter("Sheet1")
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &xlsxEncoder{w: w, file: file, stream: stream}, nil
+}
+
+func (e *xlsxEncoder) Write(record []string) error {
+	e.row++
+	cell, err := excelize.CoordinatesToCellName(1, e.row)
+	if err != nil {
+		return err
+	}
+
+	values := make([]interface{}, len(record))
+	for idx, value := range record {
+		values[idx] = value
+	}
+	return e.stream.SetRow(cell, values)
+}
+
+func (e *xlsxEncoder) Flush() error {
+	defer e.file.Close()
+
+	err := e.stream.Flush()
+	if err != nil {
+		return err
+	}
+	return e.file.Write(e.w)
+}
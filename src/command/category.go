@@ -0,0 +1,176 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/daint23/gofiberpg/src/domain"
+	"github.com/daint23/gofiberpg/src/http/request"
+	"github.com/daint23/gofiberpg/src/http/response"
+	"github.com/daint23/gofiberpg/src/route"
+	"github.com/daint23/gofiberpg/src/service"
+)
+
+// importFile reads categories from --file, "-" is stdin. The result is
+// printed as JSON, and the command fails when a row failed.
+func importFile(ctx context.Context, env *Env, args []string) error {
+	req := &request.ImportRequest{}
+	flags := newFlagSet("import")
+	file := flags.String("file", "", "file to import, - for stdin")
+	flags.StringVar(&req.Mode, "mode", "", "insert, upsert, skip-existing or replace")
+	flags.StringVar(&req.Format, "format", "", "file format, detected from the file name when empty")
+	flags.StringVar(&req.Mapping, "mapping", "", "column mapping, e.g. name:title")
+	flags.StringVar(&req.Delimiter, "delimiter", "", "csv delimiter")
+	flags.StringVar(&req.Quote, "quote", "", "csv quote")
+	flags.BoolVar(&req.DryRun, "dry-run", false, "validate only, write nothing")
+	errFlag := flags.Parse(args)
+	if errFlag != nil {
+		return errFlag
+	}
+	if *file == "" {
+		return errors.New("--file is required")
+	}
+
+	var r io.Reader = os.Stdin
+	fileName := ""
+	if *file != "-" {
+		f, errOpen := os.Open(*file)
+		if errOpen != nil {
+			return errOpen
+		}
+		defer f.Close()
+		r, fileName = f, filepath.Base(*file)
+	}
+
+	categoryService := route.NewCategoryService(env.TxManager(), env.DB, env.Validate, env.Config)
+	result, errImport := categoryService.ImportFile(ctx, r, fileName, req)
+	if errImport != nil {
+		return errImport
+	}
+
+	rowErrors := service.ToImportRowErrorResponses(result.Errors)
+	encoder := json.NewEncoder(env.Out)
+	encoder.SetIndent("", "  ")
+	errOut := encoder.Encode(&response.ImportResultResponse{
+		DryRun:   result.DryRun,
+		Inserted: result.Inserted,
+		Updated:  result.Updated,
+		Skipped:  result.Skipped,
+		Failed:   result.Failed,
+		Errors:   rowErrors,
+	})
+	if errOut != nil {
+		return errOut
+	}
+	if failed := result.Failed; failed > 0 {
+		return fmt.Errorf("%d rows failed", failed)
+	}
+	return nil
+}
+
+// export writes categories to --out, "-" is stdout. A file is written next
+// to its final name first, so a failed export never leaves half a file.
+func export(ctx context.Context, env *Env, args []string) error {
+	req := &request.ExportRequest{Filters: url.Values{}}
+	var filters listFlag
+	flags := newFlagSet("export")
+	out := flags.String("out", "-", "file to write, - for stdout")
+	flags.StringVar(&req.Format, "format", "csv", "csv, tsv, ndjson or xlsx")
+	flags.StringVar(&req.Delimiter, "delimiter", "", "csv delimiter")
+	flags.StringVar(&req.Quote, "quote", "", "csv quote")
+	flags.BoolVar(&req.Bom, "bom", false, "start csv with a byte order mark")
+	flags.StringVar(&req.Sort, "sort", "", "sort keys, e.g. -updatedAt,name")
+	flags.Var(&filters, "filter", "filter as in the query string, e.g. name[like]=book; repeatable")
+	errFlag := flags.Parse(args)
+	if errFlag != nil {
+		return errFlag
+	}
+	for _, filter := range filters {
+		key, value, ok := strings.Cut(filter, "=")
+		if !ok {
+			return fmt.Errorf("invalid filter %q, want key=value", filter)
+		}
+		req.Filters.Add(key, value)
+	}
+
+	categoryService := route.NewCategoryService(env.TxManager(), env.DB, env.Validate, env.Config)
+	categoryExport, errExport := categoryService.NewExport(req, "")
+	if errExport != nil {
+		return errExport
+	}
+
+	if *out == "-" {
+		return categoryService.ImportCsv(ctx, env.Out, categoryExport)
+	}
+
+	tmp, errTmp := os.CreateTemp(filepath.Dir(*out), "."+filepath.Base(*out)+".*")
+	if errTmp != nil {
+		return errTmp
+	}
+	defer os.Remove(tmp.Name())
+
+	errWrite := categoryService.ImportCsv(ctx, tmp, categoryExport)
+	errClose := tmp.Close()
+	if errWrite != nil {
+		return errWrite
+	}
+	if errClose != nil {
+		return errClose
+	}
+
+	// CreateTemp membuat file 0600, samakan dengan file biasa atau file lama
+	mode := os.FileMode(0o644)
+	if info, errStat := os.Stat(*out); errStat == nil {
+		mode = info.Mode().Perm()
+	}
+	errMode := os.Chmod(tmp.Name(), mode)
+	if errMode != nil {
+		return errMode
+	}
+	return os.Rename(tmp.Name(), *out)
+}
+
+// seedCategories is a small tree for local development, parents first.
+var seedCategories = []*request.CategoryCreateRequest{
+	{Name: "Electronics", Description: "Devices and accessories"},
+	{Name: "Phones", Parent: "Electronics"},
+	{Name: "Laptops", Parent: "Electronics"},
+	{Name: "Books", Description: "Printed and digital books"},
+	{Name: "Fiction", Parent: "Books"},
+	{Name: "Non-fiction", Parent: "Books"},
+	{Name: "Home", Description: "Furniture and household"},
+	{Name: "Kitchen", Parent: "Home"},
+}
+
+// seed inserts seedCategories. Categories that already exist are left as
+// they are, so seed can run more than once.
+func seed(ctx context.Context, env *Env, args []string) error {
+	errFlag := newFlagSet("seed").Parse(args)
+	if errFlag != nil {
+		return errFlag
+	}
+
+	categoryService := route.NewCategoryService(env.TxManager(), env.DB, env.Validate, env.Config)
+	inserted := 0
+	for _, req := range seedCategories {
+		seedReq := *req
+		_, err := categoryService.Insert(ctx, &seedReq)
+		if errors.Is(err, domain.ErrConflict) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("seed %s: %w", req.Name, err)
+		}
+		inserted++
+	}
+
+	fmt.Fprintln(env.Out, "seeded", inserted, "of", len(seedCategories), "categories")
+	return nil
+}
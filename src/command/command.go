@@ -0,0 +1,190 @@
+// Package command is the command line of the binary. Every command loads
+// the same config and database as the HTTP server.
+package command
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/daint23/gofiberpg/src/config"
+	"github.com/daint23/gofiberpg/src/database"
+	"github.com/daint23/gofiberpg/src/migrations"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Env is shared by every command. DB is nil for an Offline command.
+type Env struct {
+	Config   *config.Config
+	DB       *pgxpool.Pool
+	Validate *validator.Validate
+	Out      io.Writer
+}
+
+func (e *Env) TxManager() database.TxManager {
+	return database.NewTxManager(e.DB)
+}
+
+func (e *Env) Migrator() (database.Migrator, error) {
+	return database.NewMigrator(e.DB, migrations.FS)
+}
+
+type Command struct {
+	Name  string
+	Usage string
+	Run   func(ctx context.Context, env *Env, args []string) error
+	// Offline commands run without the database, and with an invalid
+	// config.
+	Offline bool
+}
+
+var commands = []*Command{
+	{Name: "serve", Usage: "serve [--addr :8089]", Run: serve},
+	{Name: "migrate", Usage: "migrate up [N] | down N | status | force VERSION", Run: migrate},
+	{Name: "import", Usage: "import --file x.csv [--mode upsert] [--format csv] [--dry-run]", Run: importFile},
+	{Name: "export", Usage: "export [--format ndjson] [--out -] [--sort name] [--filter name[like]=x]", Run: export},
+	{Name: "seed", Usage: "seed", Run: seed},
+	{Name: "config", Usage: "config print", Run: printConfig, Offline: true},
+}
+
+// Execute runs the command named by args[0], serve when args is empty, and
+// returns the exit code. Flags before the command set config keys, e.g.
+// --http-addr for HTTP_ADDR, or name the config file with --config.
+func Execute(args []string) int {
+	flags := newFlagSet("app")
+	configFile := flags.String("config", ".env", "config file")
+	for _, setting := range config.Settings {
+		flags.Var(&settingFlag{setting: setting}, setting.FlagName(), setting.Usage)
+	}
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: app [flags] [command]")
+		usage(flags.Output())
+		fmt.Fprintln(flags.Output(), "flags:")
+		flags.PrintDefaults()
+	}
+	errFlag := flags.Parse(args)
+	if errors.Is(errFlag, flag.ErrHelp) {
+		return 0
+	}
+	if errFlag != nil {
+		return 2
+	}
+
+	source := &config.Source{File: *configFile, Flags: map[string]string{}}
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "config" {
+			source.FileRequired = true
+		} else if setting, ok := f.Value.(*settingFlag); ok {
+			source.Flags[setting.setting.Key] = f.Value.String()
+		}
+	})
+
+	args = flags.Args()
+	if len(args) == 0 {
+		args = []string{"serve"}
+	}
+
+	var cmd *Command
+	for _, candidate := range commands {
+		if candidate.Name == args[0] {
+			cmd = candidate
+		}
+	}
+	if cmd == nil {
+		code := 2
+		if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+			code = 0
+		} else {
+			fmt.Fprintln(os.Stderr, "unknown command", args[0])
+		}
+		usage(os.Stderr)
+		return code
+	}
+
+	cfg, errCfg := config.Load(source)
+	var errInvalid *config.Error
+	if errCfg != nil && !(cmd.Offline && errors.As(errCfg, &errInvalid)) {
+		log.Println("=>", errCfg)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	env := &Env{Config: cfg, Validate: validator.New(), Out: os.Stdout}
+	if !cmd.Offline {
+		db, errDB := config.NewDB(ctx, cfg)
+		if errDB != nil {
+			log.Println("=>", errDB)
+			return 1
+		}
+		defer db.Close()
+		env.DB = db
+	}
+	err := cmd.Run(ctx, env, args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		log.Println("=>", cmd.Name+":", err)
+		return 1
+	}
+	return 0
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintln(w, "  "+cmd.Usage)
+	}
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet(name, flag.ContinueOnError)
+}
+
+// settingFlag sets a config key from the command line.
+type settingFlag struct {
+	setting *config.Setting
+	value   string
+}
+
+func (f *settingFlag) String() string {
+	if f.setting == nil {
+		return ""
+	}
+	if f.value == "" {
+		return fmt.Sprint(f.setting.Default)
+	}
+	return f.value
+}
+
+func (f *settingFlag) Set(value string) error {
+	f.value = value
+	return nil
+}
+
+func (f *settingFlag) IsBoolFlag() bool {
+	_, ok := f.setting.Default.(bool)
+	return ok
+}
+
+// listFlag collects a flag given more than once.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
@@ -0,0 +1,22 @@
+package command
+
+import (
+	"context"
+	"errors"
+)
+
+const configUsage = "usage: config print"
+
+// printConfig prints the effective config with secrets redacted. An
+// invalid config is printed too, followed by its problems.
+func printConfig(ctx context.Context, env *Env, args []string) error {
+	if len(args) != 1 || args[0] != "print" {
+		return errors.New(configUsage)
+	}
+
+	errPrint := env.Config.Print(env.Out)
+	if errPrint != nil {
+		return errPrint
+	}
+	return env.Config.Validate()
+}
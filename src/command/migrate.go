@@ -0,0 +1,105 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/daint23/gofiberpg/src/database"
+)
+
+const migrateUsage = "usage: migrate up [N] | down N | status | force VERSION"
+
+func migrate(ctx context.Context, env *Env, args []string) error {
+	migrator, err := env.Migrator()
+	if err != nil {
+		return err
+	}
+	return runMigrate(ctx, migrator, args, env.Out)
+}
+
+// runMigrate runs the words after "migrate" against migrator.
+func runMigrate(ctx context.Context, migrator database.Migrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		n, err := countArg(args[1:], 0)
+		if err != nil {
+			return err
+		}
+		done, errUp := migrator.Up(ctx, n)
+		for _, migration := range done {
+			fmt.Fprintf(out, "applied %d_%s\n", migration.Version, migration.Name)
+		}
+		if errUp == nil && len(done) == 0 {
+			fmt.Fprintln(out, "no pending migrations")
+		}
+		return errUp
+	case "down":
+		// down wajib pakai N supaya tidak sengaja rollback semua
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		n, err := countArg(args[1:], 1)
+		if err != nil {
+			return err
+		}
+		done, errDown := migrator.Down(ctx, n)
+		for _, migration := range done {
+			fmt.Fprintf(out, "reverted %d_%s\n", migration.Version, migration.Name)
+		}
+		return errDown
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			if status.Missing {
+				state += " (file missing)"
+			}
+			fmt.Fprintf(out, "%d_%s\t%s\n", status.Version, status.Name, state)
+		}
+		return nil
+	case "force":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		errForce := migrator.Force(ctx, version)
+		if errForce != nil {
+			return errForce
+		}
+		fmt.Fprintln(out, "schema version forced to", version)
+		return nil
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+func countArg(args []string, min int) (int, error) {
+	if len(args) == 0 {
+		return 0, nil
+	}
+	if len(args) > 1 {
+		return 0, errors.New(migrateUsage)
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < min {
+		return 0, fmt.Errorf("invalid count %q", args[0])
+	}
+	return n, nil
+}
@@ -0,0 +1,85 @@
+package command
+
+import (
+	"context"
+
+	"github.com/daint23/gofiberpg/src/helper"
+	"github.com/daint23/gofiberpg/src/route"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+)
+
+// serve starts the HTTP server. It refuses to start while migrations are
+// pending, unless MIGRATE_ON_STARTUP applies them first.
+func serve(ctx context.Context, env *Env, args []string) error {
+	flags := newFlagSet("serve")
+	addr := flags.String("addr", env.Config.HTTP.Addr, "address to listen on")
+	errFlag := flags.Parse(args)
+	if errFlag != nil {
+		return errFlag
+	}
+
+	migrator, errMigrator := env.Migrator()
+	if errMigrator != nil {
+		return errMigrator
+	}
+	if env.Config.MigrateOnStartup {
+		_, errUp := migrator.Up(ctx, 0)
+		if errUp != nil {
+			return errUp
+		}
+	}
+	errCheck := migrator.Check(ctx)
+	if errCheck != nil {
+		return errCheck
+	}
+
+	config := fiber.Config{
+		CaseSensitive: true,
+		StrictRouting: true,
+		ServerHeader:  "Fiber",
+		AppName:       env.Config.HTTP.AppName,
+		ErrorHandler:  helper.NewHTTPErrorHandler,
+		BodyLimit:     env.Config.HTTP.BodyLimit,
+		// upload dibaca dari stream, bukan di-buffer utuh; batasnya di NewBodyLimitMiddleware
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+	}
+
+	configLog := logger.Config{
+		Format:     "${pid} ${status} - ${time} ${latency} ${method} ${path}\n",
+		TimeFormat: "02-01-2006",
+		TimeZone:   "UTC",
+		Output:     helper.LogDebug(env.Config.Log.Path),
+	}
+
+	app := fiber.New(config)
+
+	app.Use(logger.New(configLog))
+	app.Use(recover.New())
+	app.Use(helper.NewBodyLimitMiddleware(env.Config.HTTP.BodyLimit, env.Config.HTTP.UploadLimit))
+	app.Use(requestid.New(requestid.Config{ContextKey: helper.RequestIDKey}))
+	app.Use(helper.NewActorMiddleware(env.Config.HTTP.ActorHeader))
+
+	app.Use(cors.New(cors.Config{
+		AllowOrigins:     env.Config.HTTP.CorsOrigins,
+		AllowHeaders:     "Origin, Content-Type, Accept, If-Match, If-None-Match, " + env.Config.HTTP.ActorHeader,
+		ExposeHeaders:    "ETag, Link, X-Request-ID",
+		AllowMethods:     "GET, POST, PUT, PATCH, DELETE",
+		AllowCredentials: true,
+	}))
+
+	waitJobs := route.ApiRoute(ctx, app, env.DB, env.Validate, env.Config)
+
+	go func() {
+		<-ctx.Done()
+		app.Shutdown()
+	}()
+	errListen := app.Listen(*addr)
+	// import job yang masih jalan berhenti bersama ctx dan disimpan sebagai failed
+	waitJobs()
+	return errListen
+}
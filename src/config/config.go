@@ -0,0 +1,223 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/viper"
+)
+
+// Config is the whole configuration of the binary. Each key is read from,
+// lowest first: its default in Settings, the config file, the environment
+// variable of the same name and the command line flag, see Load.
+type Config struct {
+	HTTP     HTTPConfig     `mapstructure:",squash"`
+	Log      LogConfig      `mapstructure:",squash"`
+	Postgres PostgresConfig `mapstructure:",squash"`
+	Import   ImportConfig   `mapstructure:",squash"`
+	Trash    TrashConfig    `mapstructure:",squash"`
+
+	MigrateOnStartup bool `mapstructure:"MIGRATE_ON_STARTUP"`
+}
+
+type HTTPConfig struct {
+	Addr           string `mapstructure:"HTTP_ADDR" validate:"required,hostname_port"`
+	AppName        string `mapstructure:"APP_NAME" validate:"required"`
+	BodyLimit      int    `mapstructure:"BODY_LIMIT" validate:"gt=0"`
+	UploadLimit    int    `mapstructure:"UPLOAD_LIMIT" validate:"gt=0"`
+	CorsOrigins    string `mapstructure:"CORS_ORIGINS" validate:"required"`
+	ActorHeader    string `mapstructure:"ACTOR_HEADER" validate:"required"`
+	RequireIfMatch bool   `mapstructure:"REQUIRE_IF_MATCH"`
+}
+
+type LogConfig struct {
+	Path string `mapstructure:"LOG_PATH" validate:"required"`
+}
+
+// PostgresConfig is the database connection. URL, when set, replaces the
+// POSTGRES_* keys.
+type PostgresConfig struct {
+	URL      string `mapstructure:"DATABASE_URL"`
+	User     string `mapstructure:"POSTGRES_USER" validate:"required_without=URL"`
+	Password string `mapstructure:"POSTGRES_PASSWORD"`
+	Service  string `mapstructure:"POSTGRES_SERVICE" validate:"required_without=URL"`
+	DB       string `mapstructure:"POSTGRES_DB" validate:"required_without=URL"`
+	SSL      string `mapstructure:"POSTGRES_SSL" validate:"oneof=disable allow prefer require verify-ca verify-full"`
+
+	MaxConns          int           `mapstructure:"DB_MAX_CONNS" validate:"gte=0"`
+	MinConns          int           `mapstructure:"DB_MIN_CONNS" validate:"gte=0"`
+	MaxConnLifetime   time.Duration `mapstructure:"DB_MAX_CONN_LIFETIME" validate:"gt=0"`
+	MaxConnIdleTime   time.Duration `mapstructure:"DB_MAX_CONN_IDLE_TIME" validate:"gt=0"`
+	HealthCheckPeriod time.Duration `mapstructure:"DB_HEALTH_CHECK_PERIOD" validate:"gt=0"`
+	StatementTimeout  time.Duration `mapstructure:"DB_STATEMENT_TIMEOUT" validate:"gte=0"`
+	ApplicationName   string        `mapstructure:"DB_APPLICATION_NAME"`
+	ConnectAttempts   int           `mapstructure:"DB_CONNECT_ATTEMPTS" validate:"gte=1"`
+	ConnectTimeout    time.Duration `mapstructure:"DB_CONNECT_TIMEOUT" validate:"gt=0"`
+	ConnectRetryDelay time.Duration `mapstructure:"DB_CONNECT_RETRY_DELAY" validate:"gt=0"`
+}
+
+type ImportConfig struct {
+	Workers        int           `mapstructure:"IMPORT_WORKERS" validate:"gte=1"`
+	Buffer         int           `mapstructure:"IMPORT_BUFFER" validate:"gte=0"`
+	MaxConcurrency int           `mapstructure:"IMPORT_MAX_CONCURRENCY" validate:"gte=0"`
+	MaxAttempts    int           `mapstructure:"IMPORT_MAX_ATTEMPTS" validate:"gte=1"`
+	RetryBaseDelay time.Duration `mapstructure:"IMPORT_RETRY_BASE_DELAY" validate:"gt=0"`
+	RetryMaxDelay  time.Duration `mapstructure:"IMPORT_RETRY_MAX_DELAY" validate:"gt=0"`
+	JobStaleAfter  time.Duration `mapstructure:"IMPORT_JOB_STALE_AFTER" validate:"gte=5s"`
+}
+
+type TrashConfig struct {
+	Retention     time.Duration `mapstructure:"TRASH_RETENTION" validate:"gt=0"`
+	PurgeInterval time.Duration `mapstructure:"TRASH_PURGE_INTERVAL" validate:"gt=0"`
+}
+
+// Source tells Load where to read from besides the defaults and the
+// environment.
+type Source struct {
+	// File is a .env file, or any other format viper knows from the
+	// extension. A missing file is skipped unless FileRequired.
+	File         string
+	FileRequired bool
+	// Flags maps keys to the values given on the command line.
+	Flags map[string]string
+}
+
+// Error lists every problem found by Validate.
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid config:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Load reads the config from source. When the only problem is an invalid
+// value it returns the config together with an *Error, so it can still be
+// printed.
+func Load(source *Source) (*Config, error) {
+	v := viper.New()
+	for _, setting := range Settings {
+		v.SetDefault(setting.Key, setting.Default)
+	}
+	v.AutomaticEnv()
+
+	if source.File != "" {
+		v.SetConfigFile(source.File)
+		errRead := v.ReadInConfig()
+		if errRead != nil && (source.FileRequired || !errors.Is(errRead, fs.ErrNotExist)) {
+			return nil, fmt.Errorf("config file %s: %w", source.File, errRead)
+		}
+	}
+	for key, value := range source.Flags {
+		v.Set(key, value)
+	}
+
+	cfg := &Config{}
+	errDecode := v.Unmarshal(cfg)
+	if errDecode != nil {
+		return nil, errDecode
+	}
+	return cfg, cfg.Validate()
+}
+
+// Validate checks every key and returns an *Error listing all problems.
+func (c *Config) Validate() error {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return field.Tag.Get("mapstructure")
+	})
+
+	var problems []string
+	err := validate.Struct(c)
+	var errFields validator.ValidationErrors
+	if errors.As(err, &errFields) {
+		for _, errField := range errFields {
+			problems = append(problems, describe(errField))
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if c.Postgres.MaxConns > 0 && c.Postgres.MinConns > c.Postgres.MaxConns {
+		problems = append(problems, "DB_MIN_CONNS must not be more than DB_MAX_CONNS")
+	}
+	if c.Postgres.URL != "" {
+		_, errURL := NewPoolConfig(&c.Postgres)
+		if errURL != nil {
+			problems = append(problems, "DATABASE_URL: "+errURL.Error())
+		}
+	}
+	if c.Import.RetryMaxDelay < c.Import.RetryBaseDelay {
+		problems = append(problems, "IMPORT_RETRY_MAX_DELAY must not be less than IMPORT_RETRY_BASE_DELAY")
+	}
+
+	if len(problems) > 0 {
+		return &Error{Problems: problems}
+	}
+	return nil
+}
+
+func describe(errField validator.FieldError) string {
+	key := errField.Field()
+	switch errField.Tag() {
+	case "required":
+		return key + " is required"
+	case "required_without":
+		return key + " is required without DATABASE_URL"
+	case "oneof":
+		return key + " must be one of: " + strings.ReplaceAll(errField.Param(), " ", ", ")
+	case "hostname_port":
+		return key + " must be host:port, e.g. :8089"
+	case "gt":
+		return key + " must be greater than " + errField.Param()
+	case "gte":
+		return key + " must be at least " + errField.Param()
+	default:
+		return fmt.Sprintf("%s fails %s %s", key, errField.Tag(), errField.Param())
+	}
+}
+
+// Print writes the effective config as KEY=value lines, with secrets
+// redacted.
+func (c *Config) Print(w io.Writer) error {
+	var err error
+	walk(reflect.ValueOf(c).Elem(), func(key string, value reflect.Value) {
+		text := fmt.Sprint(value.Interface())
+		if setting := Lookup(key); setting != nil && setting.Secret && text != "" {
+			text = redact(text)
+		}
+		if err == nil {
+			_, err = fmt.Fprintf(w, "%s=%s\n", key, text)
+		}
+	})
+	return err
+}
+
+// walk calls fn for every key of a config struct, in declaration order.
+func walk(value reflect.Value, fn func(key string, value reflect.Value)) {
+	for i := 0; i < value.NumField(); i++ {
+		key := value.Type().Field(i).Tag.Get("mapstructure")
+		if key == ",squash" {
+			walk(value.Field(i), fn)
+			continue
+		}
+		fn(key, value.Field(i))
+	}
+}
+
+// redact hides a secret. A URL keeps everything but its password, so the
+// host can still be checked.
+func redact(secret string) string {
+	u, err := url.Parse(secret)
+	if err != nil || u.Scheme == "" || u.User == nil {
+		return "[redacted]"
+	}
+	return u.Redacted()
+}
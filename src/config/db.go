@@ -4,17 +4,113 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/daint23/gofiberpg/src/helper"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/spf13/viper"
 )
 
-func NewDB(viper *viper.Viper) *pgxpool.Pool {
-	connection := fmt.Sprintf("postgresql://%s:%s@%s/%s?sslmode=%s", viper.GetString("POSTGRES_USER"), viper.GetString("POSTGRES_PASSWORD"), viper.GetString("POSTGRES_SERVICE"), viper.GetString("POSTGRES_DB"), viper.GetString("POSTGRES_SSL"))
-	dbpool, err := pgxpool.New(context.Background(), connection)
+// NewDB opens the pool and pings the database, retrying with backoff while
+// it is unreachable. Errors that a retry cannot fix, such as a wrong
+// password, are returned at once.
+func NewDB(ctx context.Context, cfg *Config) (*pgxpool.Pool, error) {
+	poolConfig, err := NewPoolConfig(&cfg.Postgres)
 	if err != nil {
-		panic(helper.NewHTTPError(404, errors.New("hei")))
+		return nil, err
 	}
-	return dbpool
+
+	retry := &helper.RetryPolicy{
+		MaxAttempts: cfg.Postgres.ConnectAttempts,
+		BaseDelay:   cfg.Postgres.ConnectRetryDelay,
+		MaxDelay:    cfg.Postgres.ConnectRetryDelay * 16,
+	}
+	target := poolConfig.ConnConfig.Host + ":" + strconv.Itoa(int(poolConfig.ConnConfig.Port)) + "/" + poolConfig.ConnConfig.Database
+
+	for attempt := 1; ; attempt++ {
+		dbpool, errPool := pgxpool.NewWithConfig(ctx, poolConfig)
+		if errPool != nil {
+			return nil, fmt.Errorf("database %s: %w", target, errPool)
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, cfg.Postgres.ConnectTimeout)
+		errPing := dbpool.Ping(pingCtx)
+		cancel()
+		if errPing == nil {
+			return dbpool, nil
+		}
+		dbpool.Close()
+
+		if !helper.IsRetryable(errPing) || attempt >= retry.MaxAttempts {
+			return nil, fmt.Errorf("database %s unreachable after %d attempts: %w", target, attempt, errPing)
+		}
+
+		delay := retry.Backoff(attempt)
+		log.Printf("=> database %s unreachable, retry in %s: %v", target, delay.Round(time.Millisecond), errPing)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("database %s: %w", target, ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// NewPoolConfig parses DATABASE_URL, or builds the URL from the POSTGRES_*
+// keys with every part escaped, and applies the pool settings.
+func NewPoolConfig(cfg *PostgresConfig) (*pgxpool.Config, error) {
+	dsn := cfg.URL
+	if dsn == "" {
+		connection := &url.URL{
+			Scheme:   "postgresql",
+			User:     url.UserPassword(cfg.User, cfg.Password),
+			Host:     cfg.Service,
+			Path:     "/" + cfg.DB,
+			RawQuery: url.Values{"sslmode": {cfg.SSL}}.Encode(),
+		}
+		dsn = connection.String()
+	}
+
+	poolConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, errors.New("invalid database url: " + hidePassword(err.Error(), cfg))
+	}
+
+	if cfg.MaxConns > 0 {
+		poolConfig.MaxConns = int32(cfg.MaxConns)
+	}
+	poolConfig.MinConns = int32(cfg.MinConns)
+	poolConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	poolConfig.MaxConnIdleTime = cfg.MaxConnIdleTime
+	poolConfig.HealthCheckPeriod = cfg.HealthCheckPeriod
+
+	params := poolConfig.ConnConfig.RuntimeParams
+	if cfg.ApplicationName != "" {
+		params["application_name"] = cfg.ApplicationName
+	}
+	if cfg.StatementTimeout > 0 {
+		params["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+	return poolConfig, nil
+}
+
+// hidePassword removes the password from msg. pgx redacts the url it quotes,
+// but not the one inside the error of url.Parse.
+func hidePassword(msg string, cfg *PostgresConfig) string {
+	passwords := []string{cfg.Password}
+	if _, rest, ok := strings.Cut(cfg.URL, "://"); ok {
+		if at := strings.LastIndex(rest, "@"); at >= 0 {
+			_, password, _ := strings.Cut(rest[:at], ":")
+			passwords = append(passwords, password)
+		}
+	}
+
+	for _, password := range passwords {
+		if password != "" {
+			msg = strings.ReplaceAll(msg, password, "xxxxx")
+		}
+	}
+	return msg
 }
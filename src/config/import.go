@@ -0,0 +1,45 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/daint23/gofiberpg/src/helper"
+	"github.com/daint23/gofiberpg/src/worker"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func NewRetryPolicy(cfg *Config) *helper.RetryPolicy {
+	return &helper.RetryPolicy{
+		MaxAttempts: cfg.Import.MaxAttempts,
+		BaseDelay:   cfg.Import.RetryBaseDelay,
+		MaxDelay:    cfg.Import.RetryMaxDelay,
+	}
+}
+
+// NewWorkerPool caps concurrent import inserts below the size of the
+// database pool, leaving half of it free for regular API requests unless
+// IMPORT_MAX_CONCURRENCY says otherwise.
+func NewWorkerPool(cfg *Config, db *pgxpool.Pool) *worker.Pool {
+	maxConns := int(db.Config().MaxConns)
+	maxConcurrency := cfg.Import.MaxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = maxConns / 2
+	}
+	if maxConcurrency > maxConns {
+		maxConcurrency = maxConns
+	}
+
+	return worker.NewPool(maxConcurrency, cfg.Import.Workers, cfg.Import.Buffer)
+}
+
+// NewInstanceId names this process as the owner of the import jobs it
+// starts, e.g. "api-1-4711-9f86d081".
+func NewInstanceId() string {
+	hostname, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), hex.EncodeToString(suffix))
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// Setting documents one config key. Every key of Config needs one, viper
+// only reads environment variables for keys it knows a default of.
+type Setting struct {
+	Key     string
+	Default any
+	Usage   string
+	// Secret values are redacted by Config.Print.
+	Secret bool
+}
+
+var Settings = []*Setting{
+	{Key: "HTTP_ADDR", Default: ":8089", Usage: "address the HTTP server listens on"},
+	{Key: "APP_NAME", Default: "Test Restapi", Usage: "name reported by the HTTP server"},
+	{Key: "BODY_LIMIT", Default: 5 * 1024 * 1024, Usage: "largest request body in bytes, uploads excepted"},
+	{Key: "UPLOAD_LIMIT", Default: 1024 * 1024 * 1024, Usage: "largest multipart upload in bytes, streamed to disk instead of memory"},
+	{Key: "CORS_ORIGINS", Default: "http://localhost:3000", Usage: "comma separated origins allowed by CORS"},
+	{Key: "ACTOR_HEADER", Default: "X-Actor", Usage: "request header naming who makes the change"},
+	{Key: "REQUIRE_IF_MATCH", Default: false, Usage: "answer 428 to updates and deletes without If-Match"},
+	{Key: "LOG_PATH", Default: "./src/logs/debug", Usage: "directory of the daily request logs"},
+	{Key: "DATABASE_URL", Default: "", Usage: "database url, replaces the POSTGRES_* keys when set", Secret: true},
+	{Key: "POSTGRES_USER", Default: "", Usage: "database user"},
+	{Key: "POSTGRES_PASSWORD", Default: "", Usage: "database password", Secret: true},
+	{Key: "POSTGRES_SERVICE", Default: "localhost:5432", Usage: "database host:port"},
+	{Key: "POSTGRES_DB", Default: "", Usage: "database name"},
+	{Key: "POSTGRES_SSL", Default: "prefer", Usage: "sslmode of the database connection"},
+	{Key: "DB_MAX_CONNS", Default: 0, Usage: "largest database pool, 0 is the pgx default"},
+	{Key: "DB_MIN_CONNS", Default: 0, Usage: "connections the pool keeps open"},
+	{Key: "DB_MAX_CONN_LIFETIME", Default: time.Hour, Usage: "age after which a connection is replaced"},
+	{Key: "DB_MAX_CONN_IDLE_TIME", Default: 30 * time.Minute, Usage: "idle time after which a connection is closed"},
+	{Key: "DB_HEALTH_CHECK_PERIOD", Default: time.Minute, Usage: "how often idle connections are checked"},
+	{Key: "DB_STATEMENT_TIMEOUT", Default: time.Duration(0), Usage: "statement_timeout of every connection, 0 is none"},
+	{Key: "DB_APPLICATION_NAME", Default: "gofiberpg", Usage: "application_name shown in pg_stat_activity"},
+	{Key: "DB_CONNECT_ATTEMPTS", Default: 5, Usage: "attempts to reach the database at startup"},
+	{Key: "DB_CONNECT_TIMEOUT", Default: 5 * time.Second, Usage: "timeout of each startup ping"},
+	{Key: "DB_CONNECT_RETRY_DELAY", Default: 500 * time.Millisecond, Usage: "first delay between startup attempts, doubling"},
+	{Key: "IMPORT_WORKERS", Default: 8, Usage: "goroutines writing rows of an import"},
+	{Key: "IMPORT_BUFFER", Default: 100, Usage: "rows read ahead of the import workers"},
+	{Key: "IMPORT_MAX_CONCURRENCY", Default: 0, Usage: "concurrent import writes, 0 is half the database pool"},
+	{Key: "IMPORT_MAX_ATTEMPTS", Default: 5, Usage: "attempts per import row on transient errors"},
+	{Key: "IMPORT_RETRY_BASE_DELAY", Default: 100 * time.Millisecond, Usage: "first retry delay of an import row"},
+	{Key: "IMPORT_RETRY_MAX_DELAY", Default: 5 * time.Second, Usage: "longest retry delay of an import row"},
+	{Key: "IMPORT_JOB_STALE_AFTER", Default: time.Minute, Usage: "silence after which a running import job is failed, jobs send a heartbeat every second"},
+	{Key: "TRASH_RETENTION", Default: 720 * time.Hour, Usage: "how long deleted categories stay in the trash"},
+	{Key: "TRASH_PURGE_INTERVAL", Default: time.Hour, Usage: "how often the trash is purged"},
+	{Key: "MIGRATE_ON_STARTUP", Default: false, Usage: "apply pending migrations before serving"},
+}
+
+// Lookup returns the setting of key, nil when there is none.
+func Lookup(key string) *Setting {
+	for _, setting := range Settings {
+		if setting.Key == key {
+			return setting
+		}
+	}
+	return nil
+}
+
+// FlagName is the command line flag of the setting, e.g. --http-addr for
+// HTTP_ADDR.
+func (s *Setting) FlagName() string {
+	return strings.ReplaceAll(strings.ToLower(s.Key), "_", "-")
+}
@@ -1,13 +1,21 @@
 package controller
 
 import (
+	"bufio"
+	"context"
 	"errors"
-	"os"
-	"path/filepath"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/url"
+	"strings"
 
 	"github.com/daint23/gofiberpg/src/domain"
 	"github.com/daint23/gofiberpg/src/helper"
 	"github.com/daint23/gofiberpg/src/http/request"
+	"github.com/daint23/gofiberpg/src/http/response"
+	"github.com/daint23/gofiberpg/src/patch"
 	"github.com/daint23/gofiberpg/src/service"
 	"github.com/gofiber/fiber/v2"
 )
@@ -15,67 +23,193 @@ import (
 type CategoryController interface {
 	Insert(ctx *fiber.Ctx) error
 	Update(ctx *fiber.Ctx) error
+	Patch(ctx *fiber.Ctx) error
 	Delete(ctx *fiber.Ctx) error
+	Restore(ctx *fiber.Ctx) error
+	Trash(ctx *fiber.Ctx) error
+	Purge(ctx *fiber.Ctx) error
 	FindById(ctx *fiber.Ctx) error
 	FindAll(ctx *fiber.Ctx) error
+	Subtree(ctx *fiber.Ctx) error
+	Ancestors(ctx *fiber.Ctx) error
+	Move(ctx *fiber.Ctx) error
+	History(ctx *fiber.Ctx) error
+	Revision(ctx *fiber.Ctx) error
+	Revert(ctx *fiber.Ctx) error
+	Search(ctx *fiber.Ctx) error
 	ExportCsv(ctx *fiber.Ctx) error
 	ImportCsv(ctx *fiber.Ctx) error
-	ExportCsvGo(ctx *fiber.Ctx) error
 }
 
 type CategoryControllerImpl struct {
 	CategoryService service.CategoryService
+	// RequireIfMatch makes Update and Delete answer 428 without If-Match.
+	RequireIfMatch bool
 }
 
-func NewCategoryController(categoryService service.CategoryService) CategoryController {
+func NewCategoryController(categoryService service.CategoryService, requireIfMatch bool) CategoryController {
 	return &CategoryControllerImpl{
 		CategoryService: categoryService,
+		RequireIfMatch:  requireIfMatch,
 	}
 }
 
-// ImportCsv implements CategoryController.
+// ImportCsv implements CategoryController. The body is streamed with
+// chunked encoding while the rows are read, so the status is sent before
+// the export can fail. When it fails midway the connection is closed
+// without the last chunk: clients see a truncated body (curl reports
+// "transfer closed with outstanding read data remaining") instead of a
+// file that only looks complete. The query is cancelled once the body
+// stream is closed, also when the client goes away.
 func (c *CategoryControllerImpl) ImportCsv(ctx *fiber.Ctx) error {
-	ctx.Set("Content-Type", "text/csv")
-	ctx.Set("Content-Disposition", "attachment; filename=output.csv")
-	err := c.CategoryService.ImportCsv(ctx.Context())
-	if err != nil {
-		panic(helper.NewHTTPError(500, err))
+	req := &request.ExportRequest{}
+	errQuery := ctx.QueryParser(req)
+	if errQuery != nil {
+		return helper.NewHTTPError(fiber.StatusBadRequest, errQuery)
+	}
+
+	req.Filters = queryValues(ctx)
+
+	export, errExport := c.CategoryService.NewExport(req, ctx.Get(fiber.HeaderAccept))
+	if errExport != nil {
+		return errExport
 	}
+	ctx.Attachment("output" + export.Format.Extension)
+	ctx.Set("Content-Type", export.Format.ContentType)
 
-	pathStorage := "./src/storage"
-	defer os.Remove(filepath.Join(pathStorage, "output.csv"))
+	// fiber.Ctx sudah dilepas saat body ditulis, jadi pakai context sendiri
+	exportCtx, cancel := context.WithCancel(helper.Detach(context.Background(), ctx.Context()))
+	reader, writer := io.Pipe()
+	go func() {
+		buffered := bufio.NewWriter(writer)
+		err := c.CategoryService.ImportCsv(exportCtx, buffered, export)
+		if err == nil {
+			err = buffered.Flush()
+		}
+		if err != nil {
+			log.Println("=> export", export.Format.Name+":", err)
+		}
+		// error selain nil membuat fasthttp memutus koneksi tanpa chunk terakhir
+		writer.CloseWithError(err)
+	}()
+	ctx.Context().SetBodyStream(&exportStream{PipeReader: reader, cancel: cancel}, -1)
+
+	return nil
+}
+
+// exportStream is the body of a streamed export. fasthttp closes it once
+// the body is sent or the client is gone, which cancels the export.
+type exportStream struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
 
-	return ctx.Download("./src/storage/output.csv")
+func (s *exportStream) Close() error {
+	s.cancel()
+	return s.PipeReader.Close()
 }
 
 // ExportCsv implements CategoryController.
 func (c *CategoryControllerImpl) ExportCsv(ctx *fiber.Ctx) error {
 	head, err := ctx.FormFile("file")
 	if err != nil {
-		panic(helper.NewHTTPError(500, err))
+		return domain.NewValidationError([]*domain.FieldError{{Field: "file", Tag: "required"}})
 	}
 
-	errBatch := c.CategoryService.ExportCsv(ctx.Context(), head)
+	req := &request.ImportRequest{}
+	errQuery := ctx.QueryParser(req)
+	if errQuery != nil {
+		return helper.NewHTTPError(fiber.StatusBadRequest, errQuery)
+	}
+
+	result, errBatch := c.CategoryService.ExportCsv(ctx.Context(), head, req)
 	if errBatch != nil {
-		panic(helper.NewHTTPError(500, errBatch))
+		return errBatch
 	}
 
-	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success export csv"})
+	rowErrors := service.ToImportRowErrorResponses(result.Errors)
+	if ctx.Query("report") == "csv" {
+		return sendRowErrors(ctx, rowErrors, "import-errors.csv")
+	}
+
+	message := "success export csv"
+	if result.DryRun {
+		message = "dry run, nothing was written"
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": message,
+		"data": &response.ImportResultResponse{
+			DryRun:   result.DryRun,
+			Inserted: result.Inserted,
+			Updated:  result.Updated,
+			Skipped:  result.Skipped,
+			Failed:   result.Failed,
+			Errors:   rowErrors,
+		},
+	})
 }
 
 // Delete implements CategoryController.
 func (c *CategoryControllerImpl) Delete(ctx *fiber.Ctx) error {
 	id, err := ctx.ParamsInt("id")
 	if err != nil {
-		panic(helper.NewHTTPError(404, errors.New("id not found")))
+		return helper.NewHTTPError(404, errors.New("id not found"))
+	}
+
+	ifMatch, errMatch := c.ifMatch(ctx)
+	if errMatch != nil {
+		return errMatch
 	}
 
-	errDel := c.CategoryService.Delete(ctx.Context(), id)
+	errDel := c.CategoryService.Delete(ctx.Context(), id, ifMatch)
 	if errDel != nil {
-		panic(helper.NewHTTPError(500, errDel))
+		return errDel
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success"})
+}
+
+// Restore implements CategoryController.
+func (c *CategoryControllerImpl) Restore(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return helper.NewHTTPError(404, errors.New("id not found"))
+	}
+
+	result, errRestore := c.CategoryService.Restore(ctx.Context(), id)
+	if errRestore != nil {
+		return errRestore
+	}
+	setETag(ctx, result)
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"data": result})
+}
+
+// Trash implements CategoryController.
+func (c *CategoryControllerImpl) Trash(ctx *fiber.Ctx) error {
+	params := &request.CategoryQueryParams{}
+	err := ctx.QueryParser(params)
+	if err != nil {
+		return helper.NewHTTPError(fiber.StatusBadRequest, err)
 	}
+	params.Filters = queryValues(ctx)
 
-	return ctx.Status(300).JSON(fiber.Map{"message": "success"})
+	result, errFind := c.CategoryService.Trash(ctx.Context(), params)
+	if errFind != nil {
+		return errFind
+	}
+
+	setPageLinks(ctx, result.Page)
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"data": result.Data, "page": result.Page})
+}
+
+// Purge implements CategoryController.
+func (c *CategoryControllerImpl) Purge(ctx *fiber.Ctx) error {
+	result, err := c.CategoryService.Purge(ctx.Context())
+	if err != nil {
+		return err
+	}
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"data": result})
 }
 
 // FindAll implements CategoryController.
@@ -83,9 +217,153 @@ func (c *CategoryControllerImpl) FindAll(ctx *fiber.Ctx) error {
 	params := &request.CategoryQueryParams{}
 	err := ctx.QueryParser(params)
 	if err != nil {
-		panic(helper.NewHTTPError(500, errors.New("Ooppss")))
+		return helper.NewHTTPError(fiber.StatusBadRequest, err)
+	}
+	params.Filters = queryValues(ctx)
+
+	result, errFind := c.CategoryService.FindAll(ctx.Context(), params)
+	if errFind != nil {
+		return errFind
+	}
+
+	setPageLinks(ctx, result.Page)
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"data": result.Data, "page": result.Page})
+}
+
+// Subtree implements CategoryController.
+func (c *CategoryControllerImpl) Subtree(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return helper.NewHTTPError(404, errors.New("id not found"))
+	}
+
+	params := &request.CategoryTreeParams{}
+	errQuery := ctx.QueryParser(params)
+	if errQuery != nil {
+		return helper.NewHTTPError(fiber.StatusBadRequest, errQuery)
+	}
+
+	result, errTree := c.CategoryService.Subtree(ctx.Context(), id, params)
+	if errTree != nil {
+		return errTree
+	}
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"data": result})
+}
+
+// Ancestors implements CategoryController.
+func (c *CategoryControllerImpl) Ancestors(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return helper.NewHTTPError(404, errors.New("id not found"))
+	}
+
+	result, errFind := c.CategoryService.Ancestors(ctx.Context(), id)
+	if errFind != nil {
+		return errFind
+	}
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"data": result})
+}
+
+// Move implements CategoryController.
+func (c *CategoryControllerImpl) Move(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return helper.NewHTTPError(404, errors.New("id not found"))
+	}
+
+	req := &request.CategoryMoveRequest{}
+	errPar := ctx.BodyParser(req)
+	if errPar != nil {
+		return helper.NewHTTPError(fiber.StatusBadRequest, errors.New("body is required"))
+	}
+
+	req.Id = id
+
+	result, errMove := c.CategoryService.Move(ctx.Context(), req)
+	if errMove != nil {
+		return errMove
+	}
+	setETag(ctx, result)
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"data": result})
+}
+
+// History implements CategoryController.
+func (c *CategoryControllerImpl) History(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return helper.NewHTTPError(404, errors.New("id not found"))
+	}
+
+	params := &request.CategoryHistoryParams{}
+	errQuery := ctx.QueryParser(params)
+	if errQuery != nil {
+		return helper.NewHTTPError(fiber.StatusBadRequest, errQuery)
+	}
+
+	result, errFind := c.CategoryService.History(ctx.Context(), id, params)
+	if errFind != nil {
+		return errFind
+	}
+
+	setPageLinks(ctx, result.Page)
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"data": result.Data, "page": result.Page})
+}
+
+// Revision implements CategoryController.
+func (c *CategoryControllerImpl) Revision(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return helper.NewHTTPError(404, errors.New("id not found"))
+	}
+	rev, errRev := ctx.ParamsInt("rev")
+	if errRev != nil {
+		return helper.NewHTTPError(404, errors.New("revision not found"))
+	}
+
+	result, errFind := c.CategoryService.Revision(ctx.Context(), id, rev)
+	if errFind != nil {
+		return errFind
+	}
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"data": result})
+}
+
+// Revert implements CategoryController.
+func (c *CategoryControllerImpl) Revert(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return helper.NewHTTPError(404, errors.New("id not found"))
+	}
+	rev, errRev := ctx.ParamsInt("rev")
+	if errRev != nil {
+		return helper.NewHTTPError(404, errors.New("revision not found"))
+	}
+
+	req := &request.CategoryRevertRequest{Id: id, Rev: rev}
+	req.IfMatch, err = c.ifMatch(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, errRevert := c.CategoryService.Revert(ctx.Context(), req)
+	if errRevert != nil {
+		return errRevert
+	}
+	setETag(ctx, result)
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"data": result})
+}
+
+// Search implements CategoryController.
+func (c *CategoryControllerImpl) Search(ctx *fiber.Ctx) error {
+	params := &request.CategorySearchParams{}
+	err := ctx.QueryParser(params)
+	if err != nil {
+		return helper.NewHTTPError(fiber.StatusBadRequest, err)
+	}
+
+	result, errSearch := c.CategoryService.Search(ctx.Context(), params)
+	if errSearch != nil {
+		return errSearch
 	}
-	result := c.CategoryService.FindAll(ctx.Context(), params)
 	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"data": result})
 }
 
@@ -93,10 +371,19 @@ func (c *CategoryControllerImpl) FindAll(ctx *fiber.Ctx) error {
 func (c *CategoryControllerImpl) FindById(ctx *fiber.Ctx) error {
 	id, err := ctx.ParamsInt("id")
 	if err != nil {
-		panic(helper.NewHTTPError(404, errors.New("id not found")))
+		return helper.NewHTTPError(404, errors.New("id not found"))
+	}
+
+	result, errFind := c.CategoryService.FindById(ctx.Context(), id)
+	if errFind != nil {
+		return errFind
 	}
 
-	result := c.CategoryService.FindById(ctx.Context(), id)
+	setETag(ctx, result)
+	ctx.Set("Accept-Patch", patch.Types)
+	if helper.MatchETag(ctx.Get(fiber.HeaderIfNoneMatch), helper.ETag(result.Version), true) {
+		return ctx.SendStatus(fiber.StatusNotModified)
+	}
 	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"data": result})
 }
 
@@ -105,9 +392,13 @@ func (c *CategoryControllerImpl) Insert(ctx *fiber.Ctx) error {
 	req := &request.CategoryCreateRequest{}
 	err := ctx.BodyParser(req)
 	if err != nil {
-		panic(helper.NewHTTPError(fiber.StatusInternalServerError, errors.New("body is required")))
+		return helper.NewHTTPError(fiber.StatusBadRequest, errors.New("body is required"))
 	}
-	result := c.CategoryService.Insert(ctx.Context(), req)
+	result, errIn := c.CategoryService.Insert(ctx.Context(), req)
+	if errIn != nil {
+		return errIn
+	}
+	setETag(ctx, result)
 	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{"data": result})
 }
 
@@ -115,33 +406,101 @@ func (c *CategoryControllerImpl) Insert(ctx *fiber.Ctx) error {
 func (c *CategoryControllerImpl) Update(ctx *fiber.Ctx) error {
 	id, err := ctx.ParamsInt("id")
 	if err != nil {
-		panic(helper.NewHTTPError(404, errors.New("id not found")))
+		return helper.NewHTTPError(404, errors.New("id not found"))
 	}
 
 	req := &request.CategoryUpdateRequest{}
 	errPar := ctx.BodyParser(req)
 	if errPar != nil {
-		panic(helper.NewHTTPError(fiber.StatusInternalServerError, errors.New("body is required")))
+		return helper.NewHTTPError(fiber.StatusBadRequest, errors.New("body is required"))
 	}
 
 	req.Id = id
+	req.IfMatch, err = c.ifMatch(ctx)
+	if err != nil {
+		return err
+	}
 
-	result := c.CategoryService.Update(ctx.Context(), req)
+	result, errUp := c.CategoryService.Update(ctx.Context(), req)
+	if errUp != nil {
+		return errUp
+	}
+	setETag(ctx, result)
 	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"data": result})
 }
 
-func (controller *CategoryControllerImpl) ExportCsvGo(ctx *fiber.Ctx) error {
-	head, err := ctx.FormFile("file")
+// Patch implements CategoryController.
+func (c *CategoryControllerImpl) Patch(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
 	if err != nil {
-		panic(helper.NewHTTPError(500, err))
+		return helper.NewHTTPError(404, errors.New("id not found"))
 	}
-	reader, errOpen := controller.CategoryService.OpenCsvFile(head)
-	if errOpen != nil {
-		panic(helper.NewHTTPError(500, errOpen))
+
+	mediaType, _, _ := mime.ParseMediaType(ctx.Get(fiber.HeaderContentType))
+	if mediaType != patch.MergePatchType && mediaType != patch.JSONPatchType {
+		ctx.Set("Accept-Patch", patch.Types)
+		return helper.NewHTTPError(fiber.StatusUnsupportedMediaType, errors.New("patch must be "+patch.MergePatchType+" or "+patch.JSONPatchType))
 	}
 
-	jobs := make(chan *domain.Category)
-	go controller.CategoryService.DispatchWorkers(jobs)
-	controller.CategoryService.ReadCsvFilePerLineThenSendToWorker(reader, jobs)
-	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"message": "oke"})
+	req := &request.CategoryPatchRequest{Id: id, ContentType: mediaType, Patch: ctx.Body()}
+	req.IfMatch, err = c.ifMatch(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, errPatch := c.CategoryService.Patch(ctx.Context(), req)
+	if errPatch != nil {
+		return errPatch
+	}
+	setETag(ctx, result)
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"data": result})
+}
+
+// ifMatch returns the If-Match header, or 428 when it is missing and
+// required.
+func (c *CategoryControllerImpl) ifMatch(ctx *fiber.Ctx) (string, error) {
+	ifMatch := ctx.Get(fiber.HeaderIfMatch)
+	if ifMatch == "" && c.RequireIfMatch {
+		return "", helper.NewHTTPError(fiber.StatusPreconditionRequired, errors.New("If-Match header is required"))
+	}
+	return ifMatch, nil
+}
+
+func setETag(ctx *fiber.Ctx, category *response.CategoryResponse) {
+	ctx.Set(fiber.HeaderETag, helper.ETag(category.Version))
+}
+
+// sendRowErrors writes the rejected rows of an import as a csv attachment.
+func sendRowErrors(ctx *fiber.Ctx, rowErrors []*response.ImportRowErrorResponse, fileName string) error {
+	ctx.Attachment(fileName)
+	ctx.Set("Content-Type", "text/csv")
+	return helper.WriteRowErrorsCsv(ctx, rowErrors)
+}
+
+// setPageLinks adds a Link header pointing at the next and previous pages,
+// keeping the other query options of the request.
+func setPageLinks(ctx *fiber.Ctx, page *response.PageResponse) {
+	var links []string
+	rels := []string{"next", "prev"}
+	for idx, cursor := range []*string{page.NextCursor, page.PrevCursor} {
+		if cursor == nil {
+			continue
+		}
+		query := queryValues(ctx)
+		query.Set("cursor", *cursor)
+		links = append(links, fmt.Sprintf(`<%s%s?%s>; rel="%s"`, ctx.BaseURL(), ctx.Path(), query.Encode(), rels[idx]))
+	}
+	if len(links) > 0 {
+		ctx.Set(fiber.HeaderLink, strings.Join(links, ", "))
+	}
+}
+
+// queryValues returns every query option, keeping repeated keys. Values are
+// decoded leniently, so a bare % as in name[ilike]=shoe% is kept.
+func queryValues(ctx *fiber.Ctx) url.Values {
+	values := url.Values{}
+	ctx.Request().URI().QueryArgs().VisitAll(func(key []byte, value []byte) {
+		values.Add(string(key), string(value))
+	})
+	return values
 }
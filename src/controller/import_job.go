@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/daint23/gofiberpg/src/domain"
+	"github.com/daint23/gofiberpg/src/helper"
+	"github.com/daint23/gofiberpg/src/http/request"
+	"github.com/daint23/gofiberpg/src/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+type ImportJobController interface {
+	Insert(ctx *fiber.Ctx) error
+	FindById(ctx *fiber.Ctx) error
+	FindAll(ctx *fiber.Ctx) error
+	FindErrors(ctx *fiber.Ctx) error
+	FindFailures(ctx *fiber.Ctx) error
+	ReplayFailure(ctx *fiber.Ctx) error
+}
+
+type ImportJobControllerImpl struct {
+	ImportJobService service.ImportJobService
+}
+
+func NewImportJobController(importJobService service.ImportJobService) ImportJobController {
+	return &ImportJobControllerImpl{
+		ImportJobService: importJobService,
+	}
+}
+
+// Insert implements ImportJobController.
+func (c *ImportJobControllerImpl) Insert(ctx *fiber.Ctx) error {
+	head, err := ctx.FormFile("file")
+	if err != nil {
+		return domain.NewValidationError([]*domain.FieldError{{Field: "file", Tag: "required"}})
+	}
+
+	req := &request.ImportRequest{}
+	errQuery := ctx.QueryParser(req)
+	if errQuery != nil {
+		return helper.NewHTTPError(fiber.StatusBadRequest, errQuery)
+	}
+
+	result, errIn := c.ImportJobService.Insert(ctx.Context(), head, req)
+	if errIn != nil {
+		return errIn
+	}
+	ctx.Location("/api/v1/imports/" + strconv.Itoa(result.Id))
+	return ctx.Status(fiber.StatusAccepted).JSON(fiber.Map{"data": result})
+}
+
+// FindById implements ImportJobController.
+func (c *ImportJobControllerImpl) FindById(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return helper.NewHTTPError(404, errors.New("id not found"))
+	}
+
+	result, errFind := c.ImportJobService.FindById(ctx.Context(), id)
+	if errFind != nil {
+		return errFind
+	}
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"data": result})
+}
+
+// FindAll implements ImportJobController.
+func (c *ImportJobControllerImpl) FindAll(ctx *fiber.Ctx) error {
+	params := &request.ImportJobQueryParams{}
+	err := ctx.QueryParser(params)
+	if err != nil {
+		return helper.NewHTTPError(fiber.StatusBadRequest, err)
+	}
+
+	result, errFind := c.ImportJobService.FindAll(ctx.Context(), params)
+	if errFind != nil {
+		return errFind
+	}
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"data": result})
+}
+
+// FindErrors implements ImportJobController.
+func (c *ImportJobControllerImpl) FindErrors(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return helper.NewHTTPError(404, errors.New("id not found"))
+	}
+
+	result, errFind := c.ImportJobService.FindErrors(ctx.Context(), id)
+	if errFind != nil {
+		return errFind
+	}
+	if ctx.Query("report") == "csv" {
+		return sendRowErrors(ctx, result, fmt.Sprintf("import-%d-errors.csv", id))
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"data": result})
+}
+
+// FindFailures implements ImportJobController.
+func (c *ImportJobControllerImpl) FindFailures(ctx *fiber.Ctx) error {
+	params := &request.ImportFailureQueryParams{}
+	err := ctx.QueryParser(params)
+	if err != nil {
+		return helper.NewHTTPError(fiber.StatusBadRequest, err)
+	}
+
+	result, errFind := c.ImportJobService.FindFailures(ctx.Context(), params)
+	if errFind != nil {
+		return errFind
+	}
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"data": result})
+}
+
+// ReplayFailure implements ImportJobController.
+func (c *ImportJobControllerImpl) ReplayFailure(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return helper.NewHTTPError(404, errors.New("id not found"))
+	}
+
+	result, errReplay := c.ImportJobService.ReplayFailure(ctx.Context(), id)
+	if errReplay != nil {
+		return errReplay
+	}
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{"data": result})
+}
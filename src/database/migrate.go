@@ -0,0 +1,377 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Migration is one pair of up and down files.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus tells whether a migration has been applied. Missing
+// marks a version recorded in the database without a file in this build.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+	Missing   bool
+}
+
+var ErrSchemaBehind = errors.New("database schema is behind")
+
+// Migrator applies the embedded migrations. Every method holds an advisory
+// lock for its whole run, so replicas starting together apply each
+// migration once.
+type Migrator interface {
+	// Up applies up to n pending migrations in version order, all of them
+	// when n is 0.
+	Up(ctx context.Context, n int) ([]*Migration, error)
+	// Down reverts the n most recently applied migrations.
+	Down(ctx context.Context, n int) ([]*Migration, error)
+	Status(ctx context.Context) ([]*MigrationStatus, error)
+	// Force records every migration up to version as applied and the rest
+	// as not applied, without running any of them. It is meant for a schema
+	// that was changed by hand.
+	Force(ctx context.Context, version int64) error
+	// Check returns ErrSchemaBehind when a migration is pending.
+	Check(ctx context.Context) error
+}
+
+type MigratorImpl struct {
+	DB         *pgxpool.Pool
+	Migrations []*Migration
+}
+
+// NewMigrator reads the migrations in fsys, see ReadMigrations.
+func NewMigrator(db *pgxpool.Pool, fsys fs.FS) (Migrator, error) {
+	migrations, err := ReadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &MigratorImpl{
+		DB:         db,
+		Migrations: migrations,
+	}, nil
+}
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// ReadMigrations reads <version>_<name>.up.sql and .down.sql pairs from the
+// root of fsys, sorted by version.
+func ReadMigrations(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, errVer := strconv.ParseInt(match[1], 10, 64)
+		if errVer != nil {
+			return nil, fmt.Errorf("migration %s: %w", entry.Name(), errVer)
+		}
+		body, errRead := fs.ReadFile(fsys, entry.Name())
+		if errRead != nil {
+			return nil, errRead
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(body)
+		} else {
+			migration.Down = string(body)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// migrationLockKey identifies the advisory lock held while migrating.
+const migrationLockKey = "schema_migrations"
+
+// withLock runs fn on one connection holding the migration lock, after
+// making sure schema_migrations exists in the shape this runner uses.
+func (m *MigratorImpl) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.DB.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, errLock := conn.Exec(ctx, "select pg_advisory_lock(hashtext($1))", migrationLockKey)
+	if errLock != nil {
+		return errLock
+	}
+	// pakai context baru supaya lock tetap dilepas walau ctx sudah dibatalkan
+	defer conn.Exec(context.Background(), "select pg_advisory_unlock(hashtext($1))", migrationLockKey)
+
+	errAdopt := m.adoptGolangMigrate(ctx, conn)
+	if errAdopt != nil {
+		return errAdopt
+	}
+	_, errTable := conn.Exec(ctx, createMigrationsTable)
+	if errTable != nil {
+		return errTable
+	}
+
+	return fn(conn)
+}
+
+const createMigrationsTable = `create table if not exists public."schema_migrations" (
+	version bigint not null,
+	name text not null,
+	applied_at timestamp with time zone not null default now(),
+	primary key(version)
+)`
+
+// adoptGolangMigrate converts a schema_migrations table left by
+// golang-migrate, (version bigint, dirty boolean) with one row for the
+// current version, into the table of this runner: every embedded migration
+// up to that version is recorded as applied. A dirty version was applied
+// only in part, so it is left pending; when its changes are in fact all
+// there, record it with migrate force.
+func (m *MigratorImpl) adoptGolangMigrate(ctx context.Context, conn *pgxpool.Conn) error {
+	SQL := `select exists (
+		select 1 from information_schema.columns
+		where table_schema = 'public' and table_name = 'schema_migrations' and column_name = 'dirty'
+	)`
+	var legacy bool
+	errLegacy := conn.QueryRow(ctx, SQL).Scan(&legacy)
+	if errLegacy != nil || !legacy {
+		return errLegacy
+	}
+
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		version, dirty := int64(-1), false
+		errVer := tx.QueryRow(ctx, "select version, dirty from schema_migrations order by version desc limit 1").Scan(&version, &dirty)
+		if errVer != nil && !errors.Is(errVer, pgx.ErrNoRows) {
+			return errVer
+		}
+		if dirty {
+			log.Printf("=> golang-migrate left version %d dirty, it is pending again; run migrate force %d if it was applied", version, version)
+			version--
+		}
+
+		_, errDrop := tx.Exec(ctx, "drop table schema_migrations")
+		if errDrop != nil {
+			return errDrop
+		}
+		_, errTable := tx.Exec(ctx, createMigrationsTable)
+		if errTable != nil {
+			return errTable
+		}
+		for _, migration := range m.Migrations {
+			if migration.Version > version {
+				break
+			}
+			_, errIn := tx.Exec(ctx, "insert into schema_migrations (version, name) values ($1, $2)", migration.Version, migration.Name)
+			if errIn != nil {
+				return errIn
+			}
+		}
+		return nil
+	})
+}
+
+func appliedVersions(ctx context.Context, conn *pgxpool.Conn) (map[int64]*MigrationStatus, error) {
+	rows, err := conn.Query(ctx, "select version, name, applied_at from schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]*MigrationStatus{}
+	for rows.Next() {
+		status := &MigrationStatus{}
+		errScan := rows.Scan(&status.Version, &status.Name, &status.AppliedAt)
+		if errScan != nil {
+			return nil, errScan
+		}
+		applied[status.Version] = status
+	}
+	return applied, rows.Err()
+}
+
+// run executes one migration file and records the result in the same
+// transaction, so a failed migration leaves nothing behind.
+func run(ctx context.Context, conn *pgxpool.Conn, migration *Migration, up bool) error {
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		body, SQL := migration.Down, "delete from schema_migrations where version = $1"
+		args := []any{migration.Version}
+		if up {
+			body, SQL = migration.Up, "insert into schema_migrations (version, name) values ($1, $2)"
+			args = append(args, migration.Name)
+		}
+
+		_, errRun := tx.Exec(ctx, body)
+		if errRun != nil {
+			return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, errRun)
+		}
+		_, errRecord := tx.Exec(ctx, SQL, args...)
+		return errRecord
+	})
+}
+
+// Up implements Migrator.
+func (m *MigratorImpl) Up(ctx context.Context, n int) ([]*Migration, error) {
+	var done []*Migration
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.Migrations {
+			if n > 0 && len(done) == n {
+				break
+			}
+			if applied[migration.Version] != nil {
+				continue
+			}
+
+			errRun := run(ctx, conn, migration, true)
+			if errRun != nil {
+				return errRun
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down implements Migrator.
+func (m *MigratorImpl) Down(ctx context.Context, n int) ([]*Migration, error) {
+	var done []*Migration
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for idx := len(m.Migrations) - 1; idx >= 0 && len(done) < n; idx-- {
+			migration := m.Migrations[idx]
+			if applied[migration.Version] == nil {
+				continue
+			}
+
+			errRun := run(ctx, conn, migration, false)
+			if errRun != nil {
+				return errRun
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status implements Migrator.
+func (m *MigratorImpl) Status(ctx context.Context) ([]*MigrationStatus, error) {
+	var statuses []*MigrationStatus
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.Migrations {
+			status := &MigrationStatus{Version: migration.Version, Name: migration.Name}
+			if recorded := applied[migration.Version]; recorded != nil {
+				status.AppliedAt = recorded.AppliedAt
+				delete(applied, migration.Version)
+			}
+			statuses = append(statuses, status)
+		}
+		for _, recorded := range applied {
+			recorded.Missing = true
+			statuses = append(statuses, recorded)
+		}
+		return nil
+	})
+
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, err
+}
+
+// Force implements Migrator.
+func (m *MigratorImpl) Force(ctx context.Context, version int64) error {
+	return m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			_, errDel := tx.Exec(ctx, "delete from schema_migrations where version > $1", version)
+			if errDel != nil {
+				return errDel
+			}
+
+			for _, migration := range m.Migrations {
+				if migration.Version > version {
+					break
+				}
+				SQL := "insert into schema_migrations (version, name) values ($1, $2) on conflict (version) do nothing"
+				_, errIn := tx.Exec(ctx, SQL, migration.Version, migration.Name)
+				if errIn != nil {
+					return errIn
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// Check implements Migrator.
+func (m *MigratorImpl) Check(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	pending := 0
+	for _, status := range statuses {
+		if status.AppliedAt == nil && !status.Missing {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: %d migrations pending, run migrate up", ErrSchemaBehind, pending)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/daint23/gofiberpg/src/helper"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Querier is what repositories run statements on. Both *pgxpool.Pool and
+// pgx.Tx satisfy it; Begin on a pgx.Tx starts a savepoint.
+type Querier interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
+
+var ErrIsoLevelChange = errors.New("cannot change isolation level inside a transaction")
+
+// TxManager runs a unit of work in one transaction carried by the context,
+// so every repository call made with that context shares it.
+type TxManager interface {
+	// WithinTx runs fn in a transaction with the default options. It commits
+	// when fn returns nil and rolls back otherwise. Called again with a
+	// context that already holds a transaction, it runs fn in a savepoint.
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+	// WithinTxOptions is WithinTx with explicit options, such as the
+	// isolation level. Options apply to the outermost transaction only.
+	WithinTxOptions(ctx context.Context, options pgx.TxOptions, fn func(ctx context.Context) error) error
+	// Querier returns the transaction held by ctx, or the pool.
+	Querier(ctx context.Context) Querier
+}
+
+type TxManagerImpl struct {
+	DB *pgxpool.Pool
+}
+
+func NewTxManager(db *pgxpool.Pool) TxManager {
+	return &TxManagerImpl{
+		DB: db,
+	}
+}
+
+type txKey struct{}
+
+type txState struct {
+	tx      pgx.Tx
+	options pgx.TxOptions
+}
+
+// WithinTx implements TxManager.
+func (m *TxManagerImpl) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return m.WithinTxOptions(ctx, pgx.TxOptions{}, fn)
+}
+
+// WithinTxOptions implements TxManager.
+func (m *TxManagerImpl) WithinTxOptions(ctx context.Context, options pgx.TxOptions, fn func(ctx context.Context) error) (err error) {
+	var tx pgx.Tx
+	outer, nested := ctx.Value(txKey{}).(*txState)
+	if nested {
+		if options.IsoLevel != "" && options.IsoLevel != outer.options.IsoLevel {
+			return ErrIsoLevelChange
+		}
+		options = outer.options
+		tx, err = outer.tx.Begin(ctx)
+	} else {
+		tx, err = m.DB.BeginTx(ctx, options)
+	}
+	if err != nil {
+		return helper.DBError(err, "transaction")
+	}
+	defer helper.CommitOrRollback(tx, &err)
+
+	return fn(context.WithValue(ctx, txKey{}, &txState{tx: tx, options: options}))
+}
+
+// Querier implements TxManager.
+func (m *TxManagerImpl) Querier(ctx context.Context) Querier {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state.tx
+	}
+	return m.DB
+}
@@ -1,7 +1,64 @@
 package domain
 
+import "time"
+
 type Category struct {
 	Id          int
 	Name        string
 	Description string
+	ParentId    *int
+	// DeletedAt is set while the category is in the trash.
+	DeletedAt *time.Time
+	// Version goes up by one on every update, see helper.ETag.
+	Version   int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	CreatedBy string
+	UpdatedBy string
+}
+
+// CategorySearchHit is one search result. Snippet is the matched text,
+// escaped for HTML, with the matching words wrapped in <mark> tags.
+type CategorySearchHit struct {
+	Category *Category
+	Score    float64
+	Snippet  string
+}
+
+// Actions recorded in CategoryRevision.
+const (
+	RevisionInsert  = "insert"
+	RevisionUpdate  = "update"
+	RevisionMove    = "move"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+	RevisionPurge   = "purge"
+)
+
+// CategoryRevision is one change of a category. Rev is the version the
+// change produced. Old is nil for an insert. A purge is the last revision
+// of a category, Old and New both hold the values it was removed with.
+type CategoryRevision struct {
+	CategoryId int
+	Rev        int
+	Action     string
+	Old        *CategorySnapshot
+	New        *CategorySnapshot
+	Actor      string
+	RequestId  string
+	CreatedAt  time.Time
+}
+
+// CategorySnapshot holds the editable values of a category at one
+// revision.
+type CategorySnapshot struct {
+	Name        string
+	Description string
+	ParentId    *int
+	DeletedAt   *time.Time
+}
+
+type CategoryRevisionPage struct {
+	Revisions []*CategoryRevision
+	HasMore   bool
 }
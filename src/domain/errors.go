@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+)
+
+// Kinds of failure returned by repositories and services. Check them with
+// errors.Is; the HTTP status for each kind is decided in
+// helper.NewHTTPErrorHandler.
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("conflict")
+	ErrValidation  = errors.New("validation failed")
+	ErrUnavailable = errors.New("service unavailable")
+	// ErrPreconditionFailed means the row changed since the client read it.
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
+
+// Error is a failure of one of the kinds above. Message is safe to show to a
+// client, Err keeps the cause (usually a pgx error) for errors.As and logs.
+type Error struct {
+	Kind    error
+	Message string
+	Err     error
+}
+
+func NewError(kind error, message string, err error) error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return e.Message + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+type FieldError struct {
+	Field string
+	Tag   string
+	Param string
+}
+
+// ValidationError lists every field of an input that was rejected.
+type ValidationError struct {
+	Fields []*FieldError
+}
+
+func NewValidationError(fields []*FieldError) error {
+	return &ValidationError{Fields: fields}
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		part := field.Field + ": " + field.Tag
+		if field.Param != "" {
+			part += "=" + field.Param
+		}
+		parts = append(parts, part)
+	}
+	return ErrValidation.Error() + ": " + strings.Join(parts, ", ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
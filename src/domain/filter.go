@@ -0,0 +1,23 @@
+package domain
+
+const (
+	FilterEq     = "eq"
+	FilterNe     = "ne"
+	FilterLt     = "lt"
+	FilterLte    = "lte"
+	FilterGt     = "gt"
+	FilterGte    = "gte"
+	FilterLike   = "like"
+	FilterIlike  = "ilike"
+	FilterIn     = "in"
+	FilterIsNull = "isnull"
+)
+
+// Filter is one condition of a listing, written in a query string as
+// field[operator]=value. Values holds more than one entry only for
+// FilterIn.
+type Filter struct {
+	Field    string
+	Operator string
+	Values   []string
+}
@@ -0,0 +1,97 @@
+package domain
+
+import "time"
+
+const (
+	ImportJobQueued    = "queued"
+	ImportJobRunning   = "running"
+	ImportJobSucceeded = "succeeded"
+	ImportJobFailed    = "failed"
+)
+
+const (
+	ImportModeInsert       = "insert"
+	ImportModeUpsert       = "upsert"
+	ImportModeSkipExisting = "skip-existing"
+	ImportModeReplace      = "replace"
+)
+
+const (
+	RowInserted = "inserted"
+	RowUpdated  = "updated"
+	RowSkipped  = "skipped"
+)
+
+type ImportOptions struct {
+	Mode   string
+	DryRun bool
+	// Mapping renames headers (lower case) to category fields.
+	Mapping map[string]string
+	// Format is the codec name given by the client, empty to detect it.
+	Format string
+	// Delimiter and Quote of a csv or tsv upload, zero for the default.
+	Delimiter rune
+	Quote     byte
+}
+
+type ImportJob struct {
+	Id            int
+	Status        string
+	Mode          string
+	FileName      string
+	RowsProcessed int
+	RowsInserted  int
+	RowsUpdated   int
+	RowsSkipped   int
+	RowsFailed    int
+	Error         string
+	// Owner is the instance running the job. It refreshes UpdatedAt while
+	// the job runs, a job whose UpdatedAt went stale lost its owner.
+	Owner      string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+}
+
+type ImportRow struct {
+	Line     int
+	Category *Category
+	// Parent references the parent category by name or by path, e.g.
+	// "Shoes/Running". Empty leaves the parent unchanged.
+	Parent string
+}
+
+type ImportRowError struct {
+	Line    int
+	Column  string
+	Message string
+}
+
+type ImportResult struct {
+	DryRun   bool
+	Inserted int64
+	Updated  int64
+	Skipped  int64
+	// Failed counts the rows with errors. A row rejected for several fields
+	// has one error per field but is one failed row, and Errors may keep the
+	// errors of only the first rows.
+	Failed int
+	Errors []*ImportRowError
+}
+
+type ImportFailure struct {
+	Id          int
+	JobId       int
+	Line        int
+	Name        string
+	Description string
+	Parent      string
+	// Mode is the import mode of the job, a replay writes the row the same
+	// way.
+	Mode       string
+	Error      string
+	Attempts   int
+	CreatedAt  time.Time
+	ReplayedAt *time.Time
+}
@@ -0,0 +1,33 @@
+package domain
+
+const (
+	PageDefaultLimit = 20
+	PageMaxLimit     = 100
+)
+
+// SortKey is one column of a listing order.
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// PageQuery asks for one page of a listing. Sort must end with a unique
+// field so the order is total. When After is set the page starts right after
+// the row with those sort values, or right before it when Backward is set.
+type PageQuery struct {
+	Filters      []Filter
+	Sort         []SortKey
+	After        []string
+	Backward     bool
+	Limit        int
+	IncludeTotal bool
+	// Trashed lists the categories in the trash instead of the live ones.
+	Trashed bool
+}
+
+type CategoryPage struct {
+	Categories []*Category
+	// HasMore reports rows beyond this page in the direction it was read.
+	HasMore bool
+	Total   *int64
+}
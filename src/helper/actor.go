@@ -0,0 +1,64 @@
+package helper
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SystemActor is recorded for changes made outside a request, e.g. the
+// trash purge.
+const SystemActor = "system"
+
+type actorKey struct{}
+
+type requestIDKey struct{}
+
+// RequestIDKey is the ContextKey for the requestid middleware, see
+// RequestID.
+var RequestIDKey any = requestIDKey{}
+
+// NewActorMiddleware reads who makes the request from header and keeps it
+// in the request context, see Actor. Requests without the header are made
+// by "anonymous".
+func NewActorMiddleware(header string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		actor := strings.TrimSpace(ctx.Get(header))
+		if actor == "" {
+			actor = "anonymous"
+		}
+		// fasthttp.RequestCtx meneruskan Value ke UserValue, jadi ctx.Context() ikut membawa actor
+		ctx.Locals(actorKey{}, actor)
+		return ctx.Next()
+	}
+}
+
+// WithActor returns a copy of ctx made by actor, for work that outlives the
+// request.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor returns who ctx is acting for, SystemActor when unknown.
+func Actor(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	if actor == "" {
+		return SystemActor
+	}
+	return actor
+}
+
+// RequestID returns the id of the request ctx belongs to, empty outside a
+// request.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(RequestIDKey).(string)
+	return requestID
+}
+
+// Detach returns a context derived from parent that keeps the actor and
+// request id of ctx, for work that outlives the request.
+func Detach(parent context.Context, ctx context.Context) context.Context {
+	detached := WithActor(parent, Actor(ctx))
+	return context.WithValue(detached, RequestIDKey, RequestID(ctx))
+}
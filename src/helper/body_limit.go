@@ -0,0 +1,53 @@
+package helper
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// NewBodyLimitMiddleware limits request bodies when the server streams
+// them, see fiber.Config.StreamRequestBody. Multipart bodies are uploads
+// and may be as large as uploadLimit, they are read from the stream by the
+// handler. Every other body may be as large as bodyLimit and is read into
+// memory here, so handlers can keep using ctx.Body.
+func NewBodyLimitMiddleware(bodyLimit int, uploadLimit int) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		req := ctx.Request()
+		length := req.Header.ContentLength()
+		reject := func(err error) error {
+			// sisa body tidak dibaca, jadi koneksinya tidak bisa dipakai request berikutnya
+			ctx.Context().SetConnectionClose()
+			return err
+		}
+
+		if strings.HasPrefix(strings.ToLower(ctx.Get(fiber.HeaderContentType)), fiber.MIMEMultipartForm) {
+			// multipart tidak dibaca ke memory, jadi panjangnya harus diketahui di depan
+			if length < 0 {
+				return reject(NewHTTPError(fiber.StatusLengthRequired, errors.New("uploads need a Content-Length")))
+			}
+			if length > uploadLimit {
+				return reject(fiber.ErrRequestEntityTooLarge)
+			}
+			return ctx.Next()
+		}
+
+		if length > bodyLimit {
+			return reject(fiber.ErrRequestEntityTooLarge)
+		}
+		if req.IsBodyStream() {
+			// body chunked belum dibaca, dibaca sampai bodyLimit saja
+			body, err := io.ReadAll(io.LimitReader(req.BodyStream(), int64(bodyLimit)+1))
+			if err != nil {
+				return reject(NewHTTPError(fiber.StatusBadRequest, err))
+			}
+			if len(body) > bodyLimit {
+				return reject(fiber.ErrRequestEntityTooLarge)
+			}
+			req.SetBody(body)
+		}
+		return ctx.Next()
+	}
+}
@@ -0,0 +1,28 @@
+package helper
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"github.com/daint23/gofiberpg/src/http/response"
+)
+
+func WriteRowErrorsCsv(w io.Writer, rowErrors []*response.ImportRowErrorResponse) error {
+	writer := csv.NewWriter(w)
+
+	errWr := writer.Write([]string{"line", "column", "message"})
+	if errWr != nil {
+		return errWr
+	}
+
+	for _, rowError := range rowErrors {
+		errWri := writer.Write([]string{strconv.Itoa(rowError.Line), rowError.Column, rowError.Message})
+		if errWri != nil {
+			return errWri
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
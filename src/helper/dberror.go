@@ -0,0 +1,37 @@
+package helper
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/daint23/gofiberpg/src/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// DBError wraps a pgx error in the matching domain error. entity names the
+// row in the messages, e.g. "category not found". Errors that fit no kind
+// are returned as they are.
+func DBError(err error, entity string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, pgx.ErrNoRows):
+		return domain.NewError(domain.ErrNotFound, entity+" not found", err)
+	case IsUniqueViolation(err):
+		return domain.NewError(domain.ErrConflict, entity+" already exists", err)
+	case IsDataException(err):
+		return domain.NewError(domain.ErrValidation, "invalid value", err)
+	case IsRetryable(err):
+		return domain.NewError(domain.ErrUnavailable, "database unavailable", err)
+	default:
+		return err
+	}
+}
+
+// IsDataException reports errors of class 22, e.g. a value that cannot be
+// cast to the column type.
+func IsDataException(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && strings.HasPrefix(pgErr.Code, "22")
+}
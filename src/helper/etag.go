@@ -0,0 +1,32 @@
+package helper
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ETag returns the strong entity tag of a row version, e.g. "3".
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// MatchETag reports whether header, an If-Match or If-None-Match value,
+// lists etag or is "*". If-Match compares strongly, so a weak tag never
+// matches there; If-None-Match passes weak to ignore the W/ prefix.
+func MatchETag(header string, etag string, weak bool) bool {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return true
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -1,9 +1,11 @@
 package helper
 
 import (
-	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 
+	"github.com/daint23/gofiberpg/src/domain"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -14,9 +16,9 @@ type WebResponse struct {
 }
 
 type WebErrorInputResponse struct {
-	Code       int             `json:"code"`
-	Status     string          `json:"status"`
-	ErrorField json.RawMessage `json:"errorField"`
+	Code       int              `json:"code"`
+	Status     string           `json:"status"`
+	ErrorField []*ErrorResponse `json:"errorField"`
 }
 
 type ServiceResponse struct {
@@ -25,6 +27,17 @@ type ServiceResponse struct {
 
 func NewHTTPErrorHandler(ctx *fiber.Ctx, err error) error {
 	ctx.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+	var validationErr *domain.ValidationError
+	if errors.As(err, &validationErr) {
+		response := WebErrorInputResponse{
+			Code:       fiber.StatusBadRequest,
+			Status:     http.StatusText(fiber.StatusBadRequest),
+			ErrorField: toErrorResponses(validationErr.Fields),
+		}
+		return ctx.Status(fiber.StatusBadRequest).JSON(response)
+	}
+
 	switch e := err.(type) {
 	case *HTTPError:
 		response := WebResponse{
@@ -33,13 +46,6 @@ func NewHTTPErrorHandler(ctx *fiber.Ctx, err error) error {
 			Message: e.Error(),
 		}
 		return ctx.Status(e.Code).JSON(response)
-	case *HTTPInputValidationError:
-		response := WebErrorInputResponse{
-			Code:       fiber.StatusBadRequest,
-			Status:     http.StatusText(fiber.StatusBadRequest),
-			ErrorField: json.RawMessage(e.Error()),
-		}
-		return ctx.Status(fiber.StatusBadRequest).JSON(response)
 	case *fiber.Error:
 		response := WebResponse{
 			Code:    e.Code,
@@ -48,15 +54,51 @@ func NewHTTPErrorHandler(ctx *fiber.Ctx, err error) error {
 		}
 		return ctx.Status(e.Code).JSON(response)
 	default:
+		code := ErrorStatus(err)
+		// cause dari database tidak dikirim ke client, hanya dicatat di log
+		message := "internal server error"
+		var domainErr *domain.Error
+		if errors.As(err, &domainErr) {
+			message = domainErr.Message
+		}
+		if code >= fiber.StatusInternalServerError {
+			log.Printf("=> request %s: %v", RequestID(ctx.Context()), err)
+		}
 		response := WebResponse{
-			Code:    fiber.StatusInternalServerError,
-			Status:  http.StatusText(fiber.StatusInternalServerError),
-			Message: e.Error(),
+			Code:    code,
+			Status:  http.StatusText(code),
+			Message: message,
 		}
 		return ctx.Status(response.Code).JSON(response)
 	}
 }
 
+// ErrorStatus maps a domain error kind to its HTTP status.
+func ErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, domain.ErrConflict):
+		return fiber.StatusConflict
+	case errors.Is(err, domain.ErrValidation):
+		return fiber.StatusBadRequest
+	case errors.Is(err, domain.ErrPreconditionFailed):
+		return fiber.StatusPreconditionFailed
+	case errors.Is(err, domain.ErrUnavailable):
+		return fiber.StatusServiceUnavailable
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
+
+func toErrorResponses(fields []*domain.FieldError) []*ErrorResponse {
+	errFields := []*ErrorResponse{}
+	for _, field := range fields {
+		errFields = append(errFields, &ErrorResponse{Field: field.Field, Tag: field.Tag, Param: field.Param})
+	}
+	return errFields
+}
+
 type HTTPError struct {
 	Code int
 	Err  error
@@ -72,15 +114,3 @@ func NewHTTPError(code int, err error) error {
 func (e *HTTPError) Error() string {
 	return e.Err.Error()
 }
-
-type HTTPInputValidationError struct {
-	Err error
-}
-
-func NewHTTPInputValidationError(err error) error {
-	return &HTTPInputValidationError{Err: err}
-}
-
-func (e *HTTPInputValidationError) Error() string {
-	return e.Err.Error()
-}
@@ -7,8 +7,7 @@ import (
 	"time"
 )
 
-func LogDebug() *os.File {
-	pathDebug := "./src/logs/debug"
+func LogDebug(pathDebug string) *os.File {
 	errMkd := os.MkdirAll(pathDebug, 0755)
 	if errMkd != nil {
 		panic(NewHTTPError(404, errMkd))
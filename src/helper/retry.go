@@ -0,0 +1,78 @@
+package helper
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// Backoff returns the delay before the given retry attempt (starting at 1),
+// doubling from BaseDelay up to MaxDelay with up to 50% jitter.
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.MaxDelay
+	if attempt < 32 {
+		delay = p.BaseDelay << (attempt - 1)
+	}
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// IsRetryable reports whether a failed statement may succeed when run
+// again: serialization failures, deadlocks and lost connections are
+// retryable, constraint and data errors are not.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code == "40001", pgErr.Code == "40P01":
+			return true
+		case strings.HasPrefix(pgErr.Code, "08"):
+			return true
+		case pgErr.Code == "53300", pgErr.Code == "57P01", pgErr.Code == "57P03":
+			return true
+		default:
+			return false
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return pgconn.SafeToRetry(err) || pgconn.Timeout(err)
+}
+
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func IsForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
+}
+
+func IsCheckViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23514"
+}
@@ -2,26 +2,36 @@ package helper
 
 import (
 	"context"
-	"fmt"
+	"log"
 
 	"github.com/jackc/pgx/v5"
 )
 
-func CommitOrRollback(tx pgx.Tx) {
-	err := recover()
-	if err != nil {
-		errRoll := tx.Rollback(context.Background())
-		if errRoll != nil {
-			fmt.Println("errRol")
-			panic(errRoll)
-		}
-		fmt.Println("errAja")
-		panic(err)
-	} else {
-		errComm := tx.Commit(context.Background())
-		if errComm != nil {
-			fmt.Println("errComm")
-			panic(errComm)
-		}
+// CommitOrRollback is deferred right after tx is started, with a pointer to
+// the named error result of the caller. The transaction is rolled back when
+// that error is set or the caller panics, and committed otherwise; a failed
+// commit is reported through err as a domain error, see DBError.
+func CommitOrRollback(tx pgx.Tx, err *error) {
+	if r := recover(); r != nil {
+		rollback(tx)
+		panic(r)
+	}
+	if *err != nil {
+		rollback(tx)
+		return
+	}
+	*err = DBError(tx.Commit(context.Background()), "transaction")
+}
+
+// RollbackAlways is the deferred counterpart of CommitOrRollback for work
+// that must never be kept, such as a dry run.
+func RollbackAlways(tx pgx.Tx) {
+	rollback(tx)
+}
+
+func rollback(tx pgx.Tx) {
+	errRoll := tx.Rollback(context.Background())
+	if errRoll != nil && errRoll != pgx.ErrTxClosed {
+		log.Println("=> rollback:", errRoll)
 	}
 }
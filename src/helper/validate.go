@@ -1,10 +1,9 @@
 package helper
 
 import (
-	"encoding/json"
-	"errors"
 	"strings"
 
+	"github.com/daint23/gofiberpg/src/domain"
 	"github.com/go-playground/validator/v10"
 )
 
@@ -15,20 +14,24 @@ type ErrorResponse struct {
 }
 
 func ValidateStruct[T any](payload T, validate *validator.Validate) error {
-	var errFields []*ErrorResponse
+	errFields := ValidationErrors(payload, validate)
+	if len(errFields) == 0 {
+		return nil
+	}
+	return domain.NewValidationError(errFields)
+}
+
+func ValidationErrors[T any](payload T, validate *validator.Validate) []*domain.FieldError {
+	var errFields []*domain.FieldError
 	err := validate.Struct(payload)
 	if err != nil {
 		for _, err := range err.(validator.ValidationErrors) {
-			var element ErrorResponse
+			var element domain.FieldError
 			element.Field = strings.ToLower(err.Field())
 			element.Tag = err.Tag()
 			element.Param = err.Param()
 			errFields = append(errFields, &element)
 		}
 	}
-	if len(errFields) == 0 {
-		return nil
-	}
-	marshaledErr, _ := json.Marshal(&errFields)
-	return errors.New(string(marshaledErr))
+	return errFields
 }
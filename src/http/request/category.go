@@ -1,17 +1,77 @@
 package request
 
+import "net/url"
+
 type CategoryCreateRequest struct {
-	Name        string `json:"name" validate:"required,min=3,max=100"`
-	Description string `json:"description"`
+	// Name may not contain "/", it separates the segments of a path.
+	Name        string `json:"name" validate:"required,min=3,max=100,excludesall=/"`
+	Description string `json:"description" validate:"max=100"`
+	ParentId    *int   `json:"parentId" validate:"omitempty,gt=0"`
+	// Parent references the parent by name or path instead of by id.
+	Parent string `json:"parent" validate:"excluded_with=ParentId,max=1000"`
 }
 
 type CategoryUpdateRequest struct {
 	Id          int    `json:"id" validate:"required"`
-	Name        string `json:"name" validate:"required,min=3,max=100"`
-	Description string `json:"description"`
+	Name        string `json:"name" validate:"required,min=3,max=100,excludesall=/"`
+	Description string `json:"description" validate:"max=100"`
+	// IfMatch is the If-Match header; empty skips the version check.
+	IfMatch string `json:"-"`
+}
+
+// CategoryPatchRequest carries a patch document as sent, ContentType tells
+// whether it is a merge patch or a JSON Patch.
+type CategoryPatchRequest struct {
+	Id          int    `validate:"required"`
+	ContentType string `validate:"required"`
+	Patch       []byte `validate:"required"`
+	IfMatch     string
+}
+
+type CategoryMoveRequest struct {
+	Id int `json:"id" validate:"required"`
+	// ParentId is the new parent; null makes the category a root.
+	ParentId *int `json:"parentId" validate:"omitempty,gt=0"`
+}
+
+type CategoryHistoryParams struct {
+	Cursor string `query:"cursor"`
+	Limit  int    `query:"limit" validate:"gte=0"`
+}
+
+type CategoryRevertRequest struct {
+	Id      int `validate:"required"`
+	Rev     int `validate:"required"`
+	IfMatch string
+}
+
+type CategoryTreeParams struct {
+	Depth int `query:"depth" validate:"gte=0"`
 }
 
 type CategoryQueryParams struct {
-	Id    int
-	Limit int
+	Cursor       string `query:"cursor"`
+	Limit        int    `query:"limit" validate:"gte=0"`
+	IncludeTotal bool   `query:"includeTotal"`
+	Sort         string `query:"sort"`
+	// Nested pages over root categories and returns each with its subtree.
+	Nested bool `query:"nested"`
+	// Filters holds the whole query string; every option not named above is
+	// read as a filter.
+	Filters url.Values `query:"-"`
+}
+
+type ExportRequest struct {
+	Format    string `query:"format"`
+	Delimiter string `query:"delimiter"`
+	Quote     string `query:"quote"`
+	Bom       bool   `query:"bom"`
+	Sort      string `query:"sort"`
+	// Filters holds the whole query string, see CategoryQueryParams.
+	Filters url.Values `query:"-"`
+}
+
+type CategorySearchParams struct {
+	Q     string `query:"q" validate:"required,max=100"`
+	Limit int    `query:"limit" validate:"gte=0"`
 }
@@ -0,0 +1,20 @@
+package request
+
+type ImportJobQueryParams struct {
+	Limit int `query:"limit"`
+}
+
+type ImportFailureQueryParams struct {
+	JobId   int  `query:"jobId"`
+	Pending bool `query:"pending"`
+	Limit   int  `query:"limit"`
+}
+
+type ImportRequest struct {
+	Mode      string `query:"mode" validate:"omitempty,oneof=insert upsert skip-existing replace"`
+	DryRun    bool   `query:"dryRun"`
+	Mapping   string `query:"mapping"`
+	Format    string `query:"format"`
+	Delimiter string `query:"delimiter"`
+	Quote     string `query:"quote"`
+}
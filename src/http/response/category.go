@@ -1,7 +1,59 @@
 package response
 
+import "time"
+
 type CategoryResponse struct {
-	Id          int    `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	Id          int                 `json:"id"`
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	ParentId    *int                `json:"parentId"`
+	DeletedAt   *time.Time          `json:"deletedAt,omitempty"`
+	Version     int                 `json:"version"`
+	CreatedAt   time.Time           `json:"createdAt"`
+	UpdatedAt   time.Time           `json:"updatedAt"`
+	CreatedBy   string              `json:"createdBy"`
+	UpdatedBy   string              `json:"updatedBy"`
+	Children    []*CategoryResponse `json:"children,omitempty"`
+}
+
+type CategorySnapshotResponse struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	ParentId    *int       `json:"parentId"`
+	DeletedAt   *time.Time `json:"deletedAt,omitempty"`
+}
+
+type CategoryRevisionResponse struct {
+	CategoryId int                       `json:"categoryId"`
+	Rev        int                       `json:"rev"`
+	Action     string                    `json:"action"`
+	Old        *CategorySnapshotResponse `json:"old"`
+	New        *CategorySnapshotResponse `json:"new"`
+	Actor      string                    `json:"actor"`
+	RequestId  string                    `json:"requestId,omitempty"`
+	CreatedAt  time.Time                 `json:"createdAt"`
+}
+
+type CategoryRevisionPageResponse struct {
+	Data []*CategoryRevisionResponse `json:"data"`
+	Page *PageResponse               `json:"page"`
+}
+
+type PurgeResponse struct {
+	Purged int64     `json:"purged"`
+	Before time.Time `json:"before"`
+}
+
+type CategoryPageResponse struct {
+	Data []*CategoryResponse `json:"data"`
+	Page *PageResponse       `json:"page"`
+}
+
+type CategorySearchResponse struct {
+	Id          int     `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	ParentId    *int    `json:"parentId"`
+	Score       float64 `json:"score"`
+	Snippet     string  `json:"snippet"`
 }
@@ -0,0 +1,51 @@
+package response
+
+import "time"
+
+type ImportJobResponse struct {
+	Id            int        `json:"id"`
+	Status        string     `json:"status"`
+	Mode          string     `json:"mode"`
+	FileName      string     `json:"fileName"`
+	RowsProcessed int        `json:"rowsProcessed"`
+	RowsInserted  int        `json:"rowsInserted"`
+	RowsUpdated   int        `json:"rowsUpdated"`
+	RowsSkipped   int        `json:"rowsSkipped"`
+	RowsFailed    int        `json:"rowsFailed"`
+	Error         string     `json:"error,omitempty"`
+	Owner         string     `json:"owner,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+	StartedAt     *time.Time `json:"startedAt,omitempty"`
+	FinishedAt    *time.Time `json:"finishedAt,omitempty"`
+	DurationMs    int64      `json:"durationMs"`
+}
+
+type ImportRowErrorResponse struct {
+	Line    int    `json:"line"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+type ImportResultResponse struct {
+	DryRun   bool                      `json:"dryRun"`
+	Inserted int64                     `json:"inserted"`
+	Updated  int64                     `json:"updated"`
+	Skipped  int64                     `json:"skipped"`
+	Failed   int                       `json:"failed"`
+	Errors   []*ImportRowErrorResponse `json:"errors"`
+}
+
+type ImportFailureResponse struct {
+	Id          int        `json:"id"`
+	JobId       int        `json:"jobId"`
+	Line        int        `json:"line"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Parent      string     `json:"parent,omitempty"`
+	Mode        string     `json:"mode"`
+	Error       string     `json:"error"`
+	Attempts    int        `json:"attempts"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ReplayedAt  *time.Time `json:"replayedAt,omitempty"`
+}
@@ -0,0 +1,8 @@
+package response
+
+type PageResponse struct {
+	Limit      int     `json:"limit"`
+	NextCursor *string `json:"nextCursor"`
+	PrevCursor *string `json:"prevCursor"`
+	Total      *int64  `json:"total,omitempty"`
+}
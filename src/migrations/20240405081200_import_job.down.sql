@@ -0,0 +1 @@
+drop table public."import_job"
\ No newline at end of file
@@ -0,0 +1,12 @@
+create table public."import_job" (
+  id serial not null,
+  status character varying(20) not null default 'queued',
+  file_name character varying(255) not null,
+  rows_processed integer not null default 0,
+  rows_failed integer not null default 0,
+  error text,
+  created_at timestamp with time zone not null default now(),
+  started_at timestamp with time zone,
+  finished_at timestamp with time zone,
+  primary key(id)
+)
\ No newline at end of file
@@ -0,0 +1 @@
+drop table public."import_job_error"
\ No newline at end of file
@@ -0,0 +1,8 @@
+create table public."import_job_error" (
+  id serial not null,
+  job_id integer not null references public."import_job"(id) on delete cascade,
+  line integer not null,
+  column_name character varying(100),
+  message text not null,
+  primary key(id)
+)
\ No newline at end of file
@@ -0,0 +1 @@
+drop table public."category_import_failures"
\ No newline at end of file
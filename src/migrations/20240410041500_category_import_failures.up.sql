@@ -0,0 +1,12 @@
+create table public."category_import_failures" (
+  id serial not null,
+  job_id integer references public."import_job"(id) on delete set null,
+  line integer not null,
+  name text not null,
+  description text,
+  error text not null,
+  attempts integer not null,
+  created_at timestamp with time zone not null default now(),
+  replayed_at timestamp with time zone,
+  primary key(id)
+)
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- every copy of a name but the oldest gets its id appended, so category_name_key can be built
+update public."category" c
+  set name = left(c.name, 100 - length(' (' || c.id || ')')) || ' (' || c.id || ')'
+  where exists (
+    select 1 from public."category" o
+    where lower(o.name) = lower(c.name) and o.id < c.id
+  )
\ No newline at end of file
@@ -0,0 +1,5 @@
+alter table public."import_job"
+  drop column mode,
+  drop column rows_inserted,
+  drop column rows_updated,
+  drop column rows_skipped
\ No newline at end of file
@@ -0,0 +1,5 @@
+alter table public."import_job"
+  add column mode character varying(20) not null default 'insert',
+  add column rows_inserted integer not null default 0,
+  add column rows_updated integer not null default 0,
+  add column rows_skipped integer not null default 0
\ No newline at end of file
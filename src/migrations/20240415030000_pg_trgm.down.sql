@@ -0,0 +1 @@
+drop extension if exists pg_trgm
\ No newline at end of file
@@ -0,0 +1 @@
+create extension if not exists pg_trgm
\ No newline at end of file
@@ -0,0 +1,2 @@
+alter table public."category"
+  drop column search
\ No newline at end of file
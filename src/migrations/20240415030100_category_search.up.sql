@@ -0,0 +1,5 @@
+alter table public."category"
+  add column search tsvector generated always as (
+    setweight(to_tsvector('simple'::regconfig, coalesce(name, '')), 'A') ||
+    setweight(to_tsvector('simple'::regconfig, coalesce(description, '')), 'B')
+  ) stored
\ No newline at end of file
@@ -0,0 +1 @@
+drop index public."category_search_idx"
\ No newline at end of file
@@ -0,0 +1 @@
+create index category_search_idx on public."category" using gin (search)
\ No newline at end of file
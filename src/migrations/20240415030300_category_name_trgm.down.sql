@@ -0,0 +1 @@
+drop index public."category_name_trgm_idx"
\ No newline at end of file
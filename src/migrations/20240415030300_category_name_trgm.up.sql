@@ -0,0 +1 @@
+create index category_name_trgm_idx on public."category" using gin (name gin_trgm_ops)
\ No newline at end of file
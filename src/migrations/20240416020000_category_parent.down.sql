@@ -0,0 +1,3 @@
+alter table public."category"
+  drop constraint category_parent_self,
+  drop column parent_id
\ No newline at end of file
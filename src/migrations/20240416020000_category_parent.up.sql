@@ -0,0 +1,3 @@
+alter table public."category"
+  add column parent_id integer references public."category"(id) on delete restrict,
+  add constraint category_parent_self check (parent_id <> id)
\ No newline at end of file
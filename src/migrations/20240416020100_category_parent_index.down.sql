@@ -0,0 +1 @@
+drop index public."category_parent_id_idx"
\ No newline at end of file
@@ -0,0 +1 @@
+create index category_parent_id_idx on public."category" (parent_id)
\ No newline at end of file
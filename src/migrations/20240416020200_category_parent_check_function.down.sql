@@ -0,0 +1 @@
+drop function public.category_parent_check()
\ No newline at end of file
@@ -0,0 +1,25 @@
+create function public.category_parent_check() returns trigger
+language plpgsql as $$
+begin
+  if new.parent_id is null then
+    return new;
+  end if;
+
+  -- tree changes are serialized so two concurrent moves cannot build a cycle
+  perform pg_advisory_xact_lock(hashtext('category_parent'));
+
+  if exists (
+    with recursive ancestors as (
+      select id, parent_id from public."category" where id = new.parent_id
+      union
+      select c.id, c.parent_id from public."category" c join ancestors a on c.id = a.parent_id
+    )
+    select 1 from ancestors where id = new.id
+  ) then
+    raise exception 'category % cannot be moved under its own subtree', new.id
+      using errcode = 'check_violation', constraint = 'category_parent_cycle';
+  end if;
+
+  return new;
+end
+$$
\ No newline at end of file
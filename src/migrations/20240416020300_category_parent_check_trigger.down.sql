@@ -0,0 +1 @@
+drop trigger category_parent_check on public."category"
\ No newline at end of file
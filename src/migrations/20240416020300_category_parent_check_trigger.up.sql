@@ -0,0 +1,3 @@
+create trigger category_parent_check
+  before insert or update of parent_id on public."category"
+  for each row execute function public.category_parent_check()
\ No newline at end of file
@@ -0,0 +1,2 @@
+alter table public."category_import_failures"
+  drop column parent
\ No newline at end of file
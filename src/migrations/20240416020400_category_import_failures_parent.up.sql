@@ -0,0 +1,2 @@
+alter table public."category_import_failures"
+  add column parent text
\ No newline at end of file
@@ -0,0 +1,2 @@
+alter table public."category"
+  drop column deleted_at
\ No newline at end of file
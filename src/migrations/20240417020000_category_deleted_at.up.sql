@@ -0,0 +1,2 @@
+alter table public."category"
+  add column deleted_at timestamp with time zone
\ No newline at end of file
@@ -0,0 +1 @@
+drop index public."category_deleted_at_idx"
\ No newline at end of file
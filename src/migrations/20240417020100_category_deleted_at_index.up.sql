@@ -0,0 +1 @@
+create index category_deleted_at_idx on public."category" (deleted_at) where deleted_at is not null
\ No newline at end of file
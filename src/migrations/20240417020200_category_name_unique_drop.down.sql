@@ -0,0 +1 @@
+create unique index category_name_key on public."category" (lower(name))
\ No newline at end of file
@@ -0,0 +1 @@
+drop index public."category_name_key"
\ No newline at end of file
@@ -0,0 +1 @@
+-- duplicate names stay renamed, the originals are not kept
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- same as category_name_dedup, for live names that collided while the index was dropped
+update public."category" c
+  set name = left(c.name, 100 - length(' (' || c.id || ')')) || ' (' || c.id || ')'
+  where c.deleted_at is null and exists (
+    select 1 from public."category" o
+    where lower(o.name) = lower(c.name) and o.id < c.id and o.deleted_at is null
+  )
\ No newline at end of file
@@ -0,0 +1 @@
+create unique index category_name_key on public."category" (lower(name)) where deleted_at is null
\ No newline at end of file
@@ -0,0 +1,2 @@
+alter table public."category"
+  add column version integer not null default 1
\ No newline at end of file
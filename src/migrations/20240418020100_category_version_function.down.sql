@@ -0,0 +1 @@
+drop function public.category_version_bump()
\ No newline at end of file
@@ -0,0 +1,7 @@
+create function public.category_version_bump() returns trigger
+language plpgsql as $$
+begin
+  new.version := old.version + 1;
+  return new;
+end
+$$
\ No newline at end of file
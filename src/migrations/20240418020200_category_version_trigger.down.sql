@@ -0,0 +1 @@
+drop trigger category_version_bump on public."category"
\ No newline at end of file
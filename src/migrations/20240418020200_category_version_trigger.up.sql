@@ -0,0 +1,3 @@
+create trigger category_version_bump
+  before update on public."category"
+  for each row execute function public.category_version_bump()
\ No newline at end of file
@@ -0,0 +1,5 @@
+alter table public."category"
+  drop column created_at,
+  drop column updated_at,
+  drop column created_by,
+  drop column updated_by
\ No newline at end of file
@@ -0,0 +1,5 @@
+alter table public."category"
+  add column created_at timestamp with time zone not null default now(),
+  add column updated_at timestamp with time zone not null default now(),
+  add column created_by text,
+  add column updated_by text
\ No newline at end of file
@@ -0,0 +1 @@
+drop table public."category_revision"
\ No newline at end of file
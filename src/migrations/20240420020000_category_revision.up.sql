@@ -0,0 +1,13 @@
+create table public."category_revision" (
+  id bigserial not null,
+  category_id integer not null,
+  rev integer not null,
+  action text not null,
+  old_values jsonb,
+  new_values jsonb not null,
+  actor text not null,
+  request_id text,
+  created_at timestamp with time zone not null default now(),
+  primary key(id),
+  unique(category_id, rev)
+)
\ No newline at end of file
@@ -0,0 +1 @@
+drop function public.category_revision_immutable()
\ No newline at end of file
@@ -0,0 +1,7 @@
+create function public.category_revision_immutable() returns trigger
+language plpgsql as $$
+begin
+  raise exception 'category revisions cannot be changed'
+    using errcode = 'restrict_violation';
+end
+$$
\ No newline at end of file
@@ -0,0 +1 @@
+drop trigger category_revision_immutable on public."category_revision"
\ No newline at end of file
@@ -0,0 +1,3 @@
+create trigger category_revision_immutable
+  before update or delete on public."category_revision"
+  for each row execute function public.category_revision_immutable()
\ No newline at end of file
@@ -0,0 +1,2 @@
+alter table public."category_import_failures"
+  drop column mode
\ No newline at end of file
@@ -0,0 +1,2 @@
+alter table public."category_import_failures"
+  add column mode character varying(20) not null default 'insert'
\ No newline at end of file
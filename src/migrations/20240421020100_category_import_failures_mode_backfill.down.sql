@@ -0,0 +1 @@
+-- the column is dropped by category_import_failures_mode
\ No newline at end of file
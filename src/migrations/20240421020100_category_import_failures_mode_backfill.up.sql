@@ -0,0 +1,4 @@
+update public."category_import_failures" f
+  set mode = j.mode
+  from public."import_job" j
+  where j.id = f.job_id
\ No newline at end of file
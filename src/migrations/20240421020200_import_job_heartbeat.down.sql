@@ -0,0 +1,3 @@
+alter table public."import_job"
+  drop column owner,
+  drop column updated_at
\ No newline at end of file
@@ -0,0 +1,3 @@
+alter table public."import_job"
+  add column owner text,
+  add column updated_at timestamp with time zone not null default now()
\ No newline at end of file
@@ -0,0 +1 @@
+-- renamed names stay renamed, the originals are not kept
\ No newline at end of file
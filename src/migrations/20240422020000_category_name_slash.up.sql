@@ -0,0 +1,25 @@
+-- names cannot contain "/" any more, it separates the segments of a path;
+-- a name that collides once "/" becomes "-" gets its id appended, like category_name_dedup.
+-- category_version_bump raises the version of every renamed row, so each rename is
+-- recorded as an update revision made by "migration"
+with renamed as (
+  update public."category" c
+    set name = case
+      when exists (
+        select 1 from public."category" o
+        where lower(replace(o.name, '/', '-')) = lower(replace(c.name, '/', '-')) and o.id <> c.id and o.deleted_at is null
+      ) then left(replace(c.name, '/', '-'), 100 - length(' (' || c.id || ')')) || ' (' || c.id || ')'
+      else replace(c.name, '/', '-')
+    end,
+    updated_at = now(),
+    updated_by = 'migration'
+    from public."category" prev
+    where prev.id = c.id and position('/' in c.name) > 0
+    returning c.id, c.version,
+      jsonb_build_object('name', prev.name, 'description', coalesce(prev.description, ''),
+        'parentId', prev.parent_id, 'deletedAt', prev.deleted_at) as old_values,
+      jsonb_build_object('name', c.name, 'description', coalesce(c.description, ''),
+        'parentId', c.parent_id, 'deletedAt', c.deleted_at) as new_values
+)
+insert into public."category_revision" (category_id, rev, action, old_values, new_values, actor)
+  select id, version, 'update', old_values, new_values, 'migration' from renamed
\ No newline at end of file
@@ -0,0 +1,2 @@
+alter table public."category"
+  drop constraint category_name_no_slash
\ No newline at end of file
@@ -0,0 +1,2 @@
+alter table public."category"
+  add constraint category_name_no_slash check (position('/' in name) = 0)
\ No newline at end of file
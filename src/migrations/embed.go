@@ -0,0 +1,18 @@
+// Package migrations holds the schema as pairs of
+// <version>_<name>.up.sql and .down.sql files, embedded into the binary.
+//
+// Applied versions are kept in schema_migrations. A table left there by
+// golang-migrate is adopted on the first run. A database whose category
+// table was created by hand, with no schema_migrations at all, has to be
+// baselined before migrate up: find the newest migration the schema
+// already matches and record it and everything before it with
+//
+//	app migrate force 20240329023907
+//
+// then run migrate up for the rest.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,271 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type operation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// applyJSONPatch applies a JSON Patch (RFC 6902). The operations run in
+// order and the first one that fails stops the whole patch.
+func applyJSONPatch(doc any, raw []byte) (any, error) {
+	var operations []operation
+	err := json.Unmarshal(raw, &operations)
+	if err != nil {
+		return nil, invalid("%s", err)
+	}
+
+	for idx, op := range operations {
+		doc, err = applyOperation(doc, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", idx, err)
+		}
+	}
+	return doc, nil
+}
+
+func applyOperation(doc any, op operation) (any, error) {
+	if op.Path == nil {
+		return nil, invalid("path is required")
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, invalid("%s needs a value", op.Op)
+		}
+		var value any
+		errValue := json.Unmarshal(op.Value, &value)
+		if errValue != nil {
+			return nil, invalid("%s", errValue)
+		}
+
+		switch op.Op {
+		case "add":
+			return add(doc, path, value)
+		case "replace":
+			return replace(doc, path, value)
+		default:
+			current, errGet := get(doc, path)
+			if errGet != nil {
+				return nil, errGet
+			}
+			if !reflect.DeepEqual(current, value) {
+				return nil, ErrTestFailed
+			}
+			return doc, nil
+		}
+	case "remove":
+		doc, _, err = remove(doc, path)
+		return doc, err
+	case "move", "copy":
+		if op.From == nil {
+			return nil, invalid("%s needs from", op.Op)
+		}
+		from, errFrom := parsePointer(*op.From)
+		if errFrom != nil {
+			return nil, errFrom
+		}
+
+		var value any
+		if op.Op == "move" {
+			if *op.Path != *op.From && strings.HasPrefix(*op.Path, *op.From+"/") {
+				return nil, invalid("cannot move %q into itself", *op.From)
+			}
+			doc, value, err = remove(doc, from)
+		} else {
+			value, err = get(doc, from)
+			if err == nil {
+				value, err = deepCopy(value)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	default:
+		return nil, invalid("unknown op %q", op.Op)
+	}
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, invalid("pointer %q must start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for idx, token := range tokens {
+		tokens[idx] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+// arrayIndex reads an array index token. "-" is the position after the last
+// element and is only accepted when end is set.
+func arrayIndex(token string, length int, end bool) (int, error) {
+	if token == "-" && end {
+		return length, nil
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 || strconv.Itoa(idx) != token {
+		return 0, invalid("bad array index %q", token)
+	}
+
+	limit := length - 1
+	if end {
+		limit = length
+	}
+	if idx > limit {
+		return 0, invalid("array index %d out of range", idx)
+	}
+	return idx, nil
+}
+
+func get(doc any, path []string) (any, error) {
+	node := doc
+	for _, token := range path {
+		switch container := node.(type) {
+		case map[string]any:
+			child, ok := container[token]
+			if !ok {
+				return nil, invalid("path %q not found", token)
+			}
+			node = child
+		case []any:
+			idx, err := arrayIndex(token, len(container), false)
+			if err != nil {
+				return nil, err
+			}
+			node = container[idx]
+		default:
+			return nil, invalid("path %q not found", token)
+		}
+	}
+	return node, nil
+}
+
+// update replaces the value at path with the result of fn, rebuilding the
+// containers above it since inserting into an array may reallocate it.
+func update(node any, path []string, fn func(any) (any, error)) (any, error) {
+	if len(path) == 0 {
+		return fn(node)
+	}
+
+	token := path[0]
+	switch container := node.(type) {
+	case map[string]any:
+		child, ok := container[token]
+		if !ok {
+			return nil, invalid("path %q not found", token)
+		}
+		value, err := update(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		container[token] = value
+		return container, nil
+	case []any:
+		idx, err := arrayIndex(token, len(container), false)
+		if err != nil {
+			return nil, err
+		}
+		value, errUp := update(container[idx], path[1:], fn)
+		if errUp != nil {
+			return nil, errUp
+		}
+		container[idx] = value
+		return container, nil
+	default:
+		return nil, invalid("path %q not found", token)
+	}
+}
+
+func add(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	last := path[len(path)-1]
+	return update(doc, path[:len(path)-1], func(parent any) (any, error) {
+		switch container := parent.(type) {
+		case map[string]any:
+			container[last] = value
+			return container, nil
+		case []any:
+			idx, err := arrayIndex(last, len(container), true)
+			if err != nil {
+				return nil, err
+			}
+			container = append(container, nil)
+			copy(container[idx+1:], container[idx:])
+			container[idx] = value
+			return container, nil
+		default:
+			return nil, invalid("cannot add %q to a value", last)
+		}
+	})
+}
+
+func remove(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, nil, invalid("cannot remove the whole document")
+	}
+
+	var removed any
+	last := path[len(path)-1]
+	result, err := update(doc, path[:len(path)-1], func(parent any) (any, error) {
+		switch container := parent.(type) {
+		case map[string]any:
+			value, ok := container[last]
+			if !ok {
+				return nil, invalid("path %q not found", last)
+			}
+			removed = value
+			delete(container, last)
+			return container, nil
+		case []any:
+			idx, err := arrayIndex(last, len(container), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = container[idx]
+			return append(container[:idx], container[idx+1:]...), nil
+		default:
+			return nil, invalid("path %q not found", last)
+		}
+	})
+	return result, removed, err
+}
+
+func replace(doc any, path []string, value any) (any, error) {
+	return update(doc, path, func(any) (any, error) {
+		return value, nil
+	})
+}
+
+func deepCopy(value any) (any, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var copied any
+	err = json.Unmarshal(raw, &copied)
+	return copied, err
+}
@@ -0,0 +1,244 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type patchCase struct {
+	name  string
+	doc   string
+	patch string
+	want  string
+	// err is the expected error, nil when want must match.
+	err error
+}
+
+func runPatchCases(t *testing.T, mediaType string, cases []patchCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Apply(mediaType, []byte(tc.doc), []byte(tc.patch))
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("error = %v, want %v", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var gotValue, wantValue any
+			if errGot := json.Unmarshal(got, &gotValue); errGot != nil {
+				t.Fatalf("result is not JSON: %s", got)
+			}
+			if errWant := json.Unmarshal([]byte(tc.want), &wantValue); errWant != nil {
+				t.Fatalf("bad test case: %v", errWant)
+			}
+			if !reflect.DeepEqual(gotValue, wantValue) {
+				t.Fatalf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+// The A.* cases are the examples of RFC 6902 appendix A.
+func TestApplyJSONPatch(t *testing.T) {
+	runPatchCases(t, JSONPatchType, []patchCase{
+		{
+			name:  "A.1 adding an object member",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz","value":"qux"}]`,
+			want:  `{"baz":"qux","foo":"bar"}`,
+		},
+		{
+			name:  "A.2 adding an array element",
+			doc:   `{"foo":["bar","baz"]}`,
+			patch: `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			want:  `{"foo":["bar","qux","baz"]}`,
+		},
+		{
+			name:  "A.3 removing an object member",
+			doc:   `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"remove","path":"/baz"}]`,
+			want:  `{"foo":"bar"}`,
+		},
+		{
+			name:  "A.4 removing an array element",
+			doc:   `{"foo":["bar","qux","baz"]}`,
+			patch: `[{"op":"remove","path":"/foo/1"}]`,
+			want:  `{"foo":["bar","baz"]}`,
+		},
+		{
+			name:  "A.5 replacing a value",
+			doc:   `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"replace","path":"/baz","value":"boo"}]`,
+			want:  `{"baz":"boo","foo":"bar"}`,
+		},
+		{
+			name:  "A.6 moving a value",
+			doc:   `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			patch: `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			want:  `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		{
+			name:  "A.7 moving an array element",
+			doc:   `{"foo":["all","grass","cows","eat"]}`,
+			patch: `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			want:  `{"foo":["all","cows","eat","grass"]}`,
+		},
+		{
+			name:  "A.8 testing a value: success",
+			doc:   `{"baz":"qux","foo":["a",2,"c"]}`,
+			patch: `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			want:  `{"baz":"qux","foo":["a",2,"c"]}`,
+		},
+		{
+			name:  "A.9 testing a value: error",
+			doc:   `{"baz":"qux"}`,
+			patch: `[{"op":"test","path":"/baz","value":"bar"}]`,
+			err:   ErrTestFailed,
+		},
+		{
+			name:  "A.10 adding a nested member object",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`,
+			want:  `{"foo":"bar","child":{"grandchild":{}}}`,
+		},
+		{
+			name:  "A.11 ignoring unrecognized elements",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz","value":"qux","xyz":123}]`,
+			want:  `{"foo":"bar","baz":"qux"}`,
+		},
+		{
+			name:  "A.12 adding to a nonexistent target",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+			err:   ErrInvalid,
+		},
+		{
+			name:  "A.13 invalid JSON Patch document",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz","value":"qux","op":"remove"}]`,
+			err:   ErrInvalid,
+		},
+		{
+			name:  "A.14 ~ escape ordering",
+			doc:   `{"/":9,"~1":10}`,
+			patch: `[{"op":"test","path":"/~01","value":10}]`,
+			want:  `{"/":9,"~1":10}`,
+		},
+		{
+			name:  "A.15 comparing strings and numbers",
+			doc:   `{"/":9,"~1":10}`,
+			patch: `[{"op":"test","path":"/~01","value":"10"}]`,
+			err:   ErrTestFailed,
+		},
+		{
+			name:  "A.16 adding an array value",
+			doc:   `{"foo":["bar"]}`,
+			patch: `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			want:  `{"foo":["bar",["abc","def"]]}`,
+		},
+		{
+			name:  "pointer with ~1 addresses a slash",
+			doc:   `{"a/b":1}`,
+			patch: `[{"op":"replace","path":"/a~1b","value":2}]`,
+			want:  `{"a/b":2}`,
+		},
+		{
+			name:  "test compares objects regardless of member order",
+			doc:   `{"a":{"x":1,"y":[1,{"z":null}]}}`,
+			patch: `[{"op":"test","path":"/a","value":{"y":[1,{"z":null}],"x":1.0}}]`,
+			want:  `{"a":{"x":1,"y":[1,{"z":null}]}}`,
+		},
+		{
+			name:  "test fails on a missing path",
+			doc:   `{"a":1}`,
+			patch: `[{"op":"test","path":"/b","value":1}]`,
+			err:   ErrInvalid,
+		},
+		{
+			name:  "move into its own child",
+			doc:   `{"a":{"b":{}}}`,
+			patch: `[{"op":"move","from":"/a","path":"/a/b/c"}]`,
+			err:   ErrInvalid,
+		},
+		{
+			name:  "move to the same path",
+			doc:   `{"a":1}`,
+			patch: `[{"op":"move","from":"/a","path":"/a"}]`,
+			want:  `{"a":1}`,
+		},
+		{
+			name:  "copy from a missing path",
+			doc:   `{"a":1}`,
+			patch: `[{"op":"copy","from":"/b","path":"/c"}]`,
+			err:   ErrInvalid,
+		},
+		{
+			name:  "copy is a deep copy",
+			doc:   `{"a":{"b":1}}`,
+			patch: `[{"op":"copy","from":"/a","path":"/c"},{"op":"replace","path":"/c/b","value":2}]`,
+			want:  `{"a":{"b":1},"c":{"b":2}}`,
+		},
+		{
+			name:  "replace a missing path",
+			doc:   `{"a":1}`,
+			patch: `[{"op":"replace","path":"/b","value":2}]`,
+			err:   ErrInvalid,
+		},
+		{
+			name:  "remove a missing path",
+			doc:   `{"a":1}`,
+			patch: `[{"op":"remove","path":"/b"}]`,
+			err:   ErrInvalid,
+		},
+		{
+			name:  "remove with - index",
+			doc:   `{"a":[1]}`,
+			patch: `[{"op":"remove","path":"/a/-"}]`,
+			err:   ErrInvalid,
+		},
+		{
+			name:  "add past the end of an array",
+			doc:   `{"a":[1]}`,
+			patch: `[{"op":"add","path":"/a/2","value":2}]`,
+			err:   ErrInvalid,
+		},
+		{
+			name:  "array index with a leading zero",
+			doc:   `{"a":[1,2]}`,
+			patch: `[{"op":"replace","path":"/a/01","value":3}]`,
+			err:   ErrInvalid,
+		},
+		{
+			name:  "add replaces the whole document",
+			doc:   `{"a":1}`,
+			patch: `[{"op":"add","path":"","value":[1]}]`,
+			want:  `[1]`,
+		},
+		{
+			name:  "pointer without a leading slash",
+			doc:   `{"a":1}`,
+			patch: `[{"op":"remove","path":"a"}]`,
+			err:   ErrInvalid,
+		},
+		{
+			name:  "unknown operation",
+			doc:   `{"a":1}`,
+			patch: `[{"op":"frobnicate","path":"/a"}]`,
+			err:   ErrInvalid,
+		},
+		{
+			name:  "operations apply in order and fail as a whole",
+			doc:   `{"a":1}`,
+			patch: `[{"op":"replace","path":"/a","value":2},{"op":"test","path":"/a","value":1}]`,
+			err:   ErrTestFailed,
+		},
+	})
+}
@@ -0,0 +1,34 @@
+package patch
+
+import "encoding/json"
+
+// applyMerge applies a JSON Merge Patch (RFC 7396). null removes a member,
+// objects are merged recursively and any other value replaces the target.
+func applyMerge(target any, raw []byte) (any, error) {
+	var patch any
+	err := json.Unmarshal(raw, &patch)
+	if err != nil {
+		return nil, invalid("%s", err)
+	}
+	return merge(target, patch), nil
+}
+
+func merge(target any, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = merge(targetObject[key], value)
+	}
+	return targetObject
+}
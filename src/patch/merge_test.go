@@ -0,0 +1,25 @@
+package patch
+
+import "testing"
+
+// The cases are the examples of RFC 7396 appendix A.
+func TestApplyMergePatch(t *testing.T) {
+	runPatchCases(t, MergePatchType, []patchCase{
+		{name: "replace a member", doc: `{"a":"b"}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{name: "add a member", doc: `{"a":"b"}`, patch: `{"b":"c"}`, want: `{"a":"b","b":"c"}`},
+		{name: "remove the only member", doc: `{"a":"b"}`, patch: `{"a":null}`, want: `{}`},
+		{name: "remove a member", doc: `{"a":"b","b":"c"}`, patch: `{"a":null}`, want: `{"b":"c"}`},
+		{name: "array replaced by string", doc: `{"a":["b"]}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{name: "string replaced by array", doc: `{"a":"c"}`, patch: `{"a":["b"]}`, want: `{"a":["b"]}`},
+		{name: "nested merge", doc: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, want: `{"a":{"b":"d"}}`},
+		{name: "arrays are replaced", doc: `{"a":[{"b":"c"}]}`, patch: `{"a":[1]}`, want: `{"a":[1]}`},
+		{name: "array document", doc: `["a","b"]`, patch: `["c","d"]`, want: `["c","d"]`},
+		{name: "object replaced by array", doc: `{"a":"b"}`, patch: `["c"]`, want: `["c"]`},
+		{name: "null patch", doc: `{"a":"foo"}`, patch: `null`, want: `null`},
+		{name: "string patch", doc: `{"a":"foo"}`, patch: `"bar"`, want: `"bar"`},
+		{name: "null members of the target stay", doc: `{"e":null}`, patch: `{"a":1}`, want: `{"e":null,"a":1}`},
+		{name: "array target replaced by object", doc: `[1,2]`, patch: `{"a":"b","c":null}`, want: `{"a":"b"}`},
+		{name: "nulls inside a new member are dropped", doc: `{}`, patch: `{"a":{"bb":{"ccc":null}}}`, want: `{"a":{"bb":{}}}`},
+		{name: "malformed patch", doc: `{}`, patch: `{"a":`, err: ErrInvalid},
+	})
+}
@@ -0,0 +1,57 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Media types of the supported patch documents.
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+// Types lists the supported media types, in the form of an Accept-Patch
+// header.
+const Types = MergePatchType + ", " + JSONPatchType
+
+var (
+	// ErrInvalid means the patch document is malformed or does not fit the
+	// target document.
+	ErrInvalid = errors.New("invalid patch")
+	// ErrTestFailed means a JSON Patch test operation did not match.
+	ErrTestFailed = errors.New("patch test failed")
+	// ErrUnsupportedType means the media type is neither of the above.
+	ErrUnsupportedType = errors.New("unsupported patch type")
+)
+
+// Apply patches the JSON document doc with a patch of the given media type
+// and returns the patched document.
+func Apply(mediaType string, doc []byte, patch []byte) ([]byte, error) {
+	var target any
+	errDoc := json.Unmarshal(doc, &target)
+	if errDoc != nil {
+		return nil, errDoc
+	}
+
+	var result any
+	var err error
+	switch mediaType {
+	case MergePatchType:
+		result, err = applyMerge(target, patch)
+	case JSONPatchType:
+		result, err = applyJSONPatch(target, patch)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, mediaType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(result)
+}
+
+func invalid(format string, args ...any) error {
+	return fmt.Errorf("%w: "+format, append([]any{ErrInvalid}, args...)...)
+}
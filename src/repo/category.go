@@ -4,198 +4,790 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"html"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/daint23/gofiberpg/src/database"
 	"github.com/daint23/gofiberpg/src/domain"
 	"github.com/daint23/gofiberpg/src/helper"
-	"github.com/daint23/gofiberpg/src/http/request"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 type CategoryRepo interface {
-	Insert(ctx context.Context, category *domain.Category) *domain.Category
-	Update(ctx context.Context, category *domain.Category) *domain.Category
+	Insert(ctx context.Context, category *domain.Category) (*domain.Category, error)
+	Update(ctx context.Context, category *domain.Category) (*domain.Category, error)
 	Delete(ctx context.Context, categoryId int) error
-	FindById(ctx context.Context, categoryId int) *domain.Category
-	FindAll(ctx context.Context, params *request.CategoryQueryParams) []*domain.Category
-	ExportCsv(ctx context.Context, valueStrings []string, valueArgs []interface{}) error
-	ImportCsv(ctx context.Context) []*domain.Category
-	ExportCsvGo(ctx context.Context, request *domain.Category) error
+	Restore(ctx context.Context, categoryId int) (*domain.Category, error)
+	Purge(ctx context.Context, before time.Time) (int64, error)
+	FindById(ctx context.Context, categoryId int) (*domain.Category, error)
+	FindByIdForUpdate(ctx context.Context, categoryId int) (*domain.Category, error)
+	FindTrashedByIdForUpdate(ctx context.Context, categoryId int) (*domain.Category, error)
+	FindRevisions(ctx context.Context, categoryId int, query *domain.PageQuery) (*domain.CategoryRevisionPage, error)
+	FindRevision(ctx context.Context, categoryId int, rev int) (*domain.CategoryRevision, error)
+	FindAll(ctx context.Context, query *domain.PageQuery) (*domain.CategoryPage, error)
+	ValidateQuery(filters []domain.Filter, sort []domain.SortKey) error
+	Search(ctx context.Context, text string, limit int) ([]*domain.CategorySearchHit, error)
+	FindSubtrees(ctx context.Context, rootIds []int, depth int) ([]*domain.Category, error)
+	FindAncestors(ctx context.Context, categoryId int) ([]*domain.Category, error)
+	FindByPath(ctx context.Context, path string) (*domain.Category, error)
+	Move(ctx context.Context, categoryId int, parentId *int) (*domain.Category, error)
+	ExportCsv(ctx context.Context, rows pgx.CopyFromSource, options *domain.ImportOptions) (*domain.ImportResult, error)
+	ImportCsv(ctx context.Context, filters []domain.Filter, sort []domain.SortKey, fn func(row *domain.ImportRow) error) error
+	ImportRow(ctx context.Context, row *domain.ImportRow, mode string) (string, *domain.Category, error)
 }
 
 type CategoryRepoImpl struct {
-	DB *pgxpool.Pool
+	TxManager database.TxManager
 }
 
-func NewCategoryRepo(db *pgxpool.Pool) CategoryRepo {
+func NewCategoryRepo(txManager database.TxManager) CategoryRepo {
 	return &CategoryRepoImpl{
-		DB: db,
+		TxManager: txManager,
 	}
 }
 
-// ImportCsv implements CategoryRepo.
-func (c *CategoryRepoImpl) ImportCsv(ctx context.Context) []*domain.Category {
-	tx, errBegin := c.DB.Begin(ctx)
+const categoryColumns = "id,name,coalesce(description,''),parent_id,deleted_at,version," +
+	"created_at,updated_at,coalesce(created_by,''),coalesce(updated_by,'')"
+
+func scanCategory(row rowScanner) (*domain.Category, error) {
+	category := &domain.Category{}
+	err := row.Scan(categoryTargets(category)...)
+	return category, err
+}
+
+// categoryTargets returns the scan destinations for categoryColumns.
+func categoryTargets(category *domain.Category) []any {
+	return []any{&category.Id, &category.Name, &category.Description, &category.ParentId, &category.DeletedAt, &category.Version,
+		&category.CreatedAt, &category.UpdatedAt, &category.CreatedBy, &category.UpdatedBy}
+}
+
+// ImportCsv implements CategoryRepo. The parent of each row is written as
+// its full path, the form ExportCsv reads back.
+func (c *CategoryRepoImpl) ImportCsv(ctx context.Context, filters []domain.Filter, sort []domain.SortKey, fn func(row *domain.ImportRow) error) error {
+	errCheck := c.ValidateQuery(filters, sort)
+	if errCheck != nil {
+		return errCheck
+	}
+
+	builder := &queryBuilder{conditions: []string{"deleted_at is null"}}
+	builder.filter(filters, categoryFilterColumns)
+	SQL := "with recursive " + categoryPathsCTE + " select name,coalesce(description,''),coalesce(path,'') from category" +
+		" left join paths on path_id = parent_id" + builder.where() + orderBy(sort, categorySortColumns, false)
+	rows, err := c.TxManager.Querier(ctx).Query(ctx, SQL, builder.args...)
+	if err != nil {
+		return dbError(err, "category")
+	}
+	defer rows.Close()
+
+	// row dibaca satu per satu dari koneksi, tidak ditampung di slice
+	row := &domain.ImportRow{Category: &domain.Category{}}
+	for rows.Next() {
+		errScan := rows.Scan(&row.Category.Name, &row.Category.Description, &row.Parent)
+		if errScan != nil {
+			return errScan
+		}
+		errFn := fn(row)
+		if errFn != nil {
+			return errFn
+		}
+	}
+	return dbError(rows.Err(), "category")
+}
+
+// ExportCsv implements CategoryRepo. Rows are copied into a temporary
+// staging table first so the conflict mode can be applied with insert ...
+// on conflict statements. A dry run does the same work and rolls it back.
+// Inside a caller's transaction the work runs in a savepoint, and the
+// staging tables are dropped before it ends so a second import in the same
+// transaction can create them again.
+//
+// Parents are looked up by the last segment of the parent column, as names
+// are unique among live categories. Rows whose parent is neither in category
+// nor in the file are rejected before the merge. The merge runs level by
+// level, a row is written once its parent exists, so every written row is
+// written once and gets one revision.
+func (c *CategoryRepoImpl) ExportCsv(ctx context.Context, rows pgx.CopyFromSource, options *domain.ImportOptions) (result *domain.ImportResult, err error) {
+	tx, errBegin := c.TxManager.Querier(ctx).Begin(ctx)
 	if errBegin != nil {
-		panic(helper.NewHTTPError(500, errBegin))
+		return nil, dbError(errBegin, "category")
+	}
+	if options.DryRun {
+		defer helper.RollbackAlways(tx)
+	} else {
+		defer helper.CommitOrRollback(tx, &err)
+	}
+	// tx bisa savepoint dari transaksi caller, jadi "on commit drop" baru jalan
+	// di commit luar dan import kedua di transaksi yang sama akan bentrok;
+	// tabel dibuang sendiri, kalau gagal rollback yang membuangnya
+	defer func() {
+		if err == nil {
+			_, errDrop := tx.Exec(ctx, "drop table category_import, category_import_pending, category_import_ready, category_import_written, category_import_old")
+			err = dbError(errDrop, "category")
+		}
+	}()
+	mode := options.Mode
+
+	SQL := `create temp table category_import (line integer, name text, description text, parent text);
+		create index on category_import (lower(name));
+		create temp table category_import_pending (line integer, name text, description text, parent text);
+		create temp table category_import_ready (line integer, name text, description text, parent text);
+		create temp table category_import_written (id integer, name_key text, inserted boolean);
+		create temp table category_import_old (id integer, old_values jsonb)`
+	_, errStage := tx.Exec(ctx, SQL)
+	if errStage != nil {
+		return nil, dbError(errStage, "category")
+	}
+
+	total, errCopy := tx.CopyFrom(ctx, pgx.Identifier{"category_import"}, []string{"line", "name", "description", "parent"}, rows)
+	if errCopy != nil {
+		return nil, dbError(errCopy, "category")
+	}
+
+	result = &domain.ImportResult{DryRun: options.DryRun}
+	result.Errors, err = rejectImportParents(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	order := "line desc"
+	if mode == domain.ImportModeInsert {
+		conflicts, errConflict := findImportConflicts(ctx, tx)
+		if errConflict != nil {
+			return nil, errConflict
+		}
+		result.Errors = append(result.Errors, conflicts...)
+		order = "line asc"
+	}
+
+	// isi lama disimpan untuk revision sebelum row ditimpa
+	SQL = fmt.Sprintf(`insert into category_import_old
+		select c.id, %s from category c
+		where c.deleted_at is null and exists (select 1 from category_import s where lower(s.name) = lower(c.name))
+		for update of c`, snapshotSQL("c"))
+	_, errOld := tx.Exec(ctx, SQL)
+	if errOld != nil {
+		return nil, dbError(errOld, "category")
+	}
+
+	SQL = fmt.Sprintf(`insert into category_import_pending
+		select distinct on (lower(name)) line, name, description, parent from category_import order by lower(name), %s`, order)
+	_, errPending := tx.Exec(ctx, SQL)
+	if errPending != nil {
+		return nil, dbError(errPending, "category")
+	}
+
+	// parent ditulis sebelum child-nya, level demi level, jadi tiap row cukup
+	// ditulis sekali dan revision-nya mulai dari version yang benar
+	actor := helper.Actor(ctx)
+	for {
+		SQL = fmt.Sprintf(`with ready as (
+				delete from category_import_pending s
+				where s.parent = '' or exists (select 1 from category p where lower(p.name) = lower(%s) and p.deleted_at is null)
+				returning s.*
+			)
+			insert into category_import_ready select * from ready`, importParentName)
+		tag, errReady := tx.Exec(ctx, SQL)
+		if errReady != nil {
+			return nil, dbError(errReady, "category")
+		}
+		if tag.RowsAffected() == 0 {
+			break
+		}
+
+		mergeErrors, errMerge := runImportStep(ctx, tx, "select line from category_import_ready order by line", func(filter string) string {
+			return fmt.Sprintf(`with written as (
+					insert into category (name, description, parent_id, created_by, updated_by)
+					select s.name, s.description, p.id, $1, $1
+					from category_import_ready s left join category p on lower(p.name) = lower(%s) and p.deleted_at is null
+					where %s
+					%s returning id, lower(name), (xmax = 0)
+				)
+				insert into category_import_written select * from written`, importParentName, filter, conflictClause(mode))
+		}, actor)
+		if errMerge != nil {
+			return nil, errMerge
+		}
+		result.Errors = append(result.Errors, mergeErrors...)
+
+		_, errClear := tx.Exec(ctx, "delete from category_import_ready")
+		if errClear != nil {
+			return nil, dbError(errClear, "category")
+		}
+	}
+
+	// yang tersisa menunggu parent dari file yang gagal ditulis
+	pending, errLeft := tx.Query(ctx, "select line from category_import_pending order by line")
+	if errLeft != nil {
+		return nil, dbError(errLeft, "category")
+	}
+	lines, errScan := pgx.CollectRows(pending, pgx.RowTo[int])
+	if errScan != nil {
+		return nil, dbError(errScan, "category")
+	}
+	for _, line := range lines {
+		result.Errors = append(result.Errors, &domain.ImportRowError{Line: line, Column: "parent", Message: "parent category could not be imported"})
+	}
+
+	SQL = fmt.Sprintf(`insert into category_revision (category_id, rev, action, old_values, new_values, actor, request_id)
+		select c.id, c.version, case when w.inserted then '%s' else '%s' end, o.old_values, %s, $1, nullif($2, '')
+		from category_import_written w join category c on c.id = w.id left join category_import_old o on o.id = w.id`,
+		domain.RevisionInsert, domain.RevisionUpdate, snapshotSQL("c"))
+	_, errRevision := tx.Exec(ctx, SQL, actor, helper.RequestID(ctx))
+	if errRevision != nil {
+		return nil, dbError(errRevision, "category revision")
+	}
+
+	SQL = "select count(*) filter (where inserted), count(*) filter (where not inserted) from category_import_written"
+	errCount := tx.QueryRow(ctx, SQL).Scan(&result.Inserted, &result.Updated)
+	if errCount != nil {
+		return nil, dbError(errCount, "category")
+	}
+
+	sort.SliceStable(result.Errors, func(i, j int) bool {
+		return result.Errors[i].Line < result.Errors[j].Line
+	})
+	// tiap line di sini punya satu error
+	result.Failed = len(result.Errors)
+	result.Skipped = total - result.Inserted - result.Updated - int64(result.Failed)
+	return result, nil
+}
+
+// runImportStep runs the statement built by step over all staged rows at
+// once, with filter "true". When a row breaks a constraint the statement is
+// rolled back and run again for each line listed by linesSQL, with filter
+// "s.line = $n", each in its own savepoint. Only the failing rows are then
+// reported instead of failing the whole import.
+func runImportStep(ctx context.Context, tx pgx.Tx, linesSQL string, step func(filter string) string, args ...any) ([]*domain.ImportRowError, error) {
+	errBatch := pgx.BeginFunc(ctx, tx, func(batch pgx.Tx) error {
+		_, err := batch.Exec(ctx, step("true"), args...)
+		return err
+	})
+	if errBatch == nil {
+		return nil, nil
+	}
+	if !isRowError(errBatch) {
+		return nil, categoryError(errBatch)
 	}
-	defer helper.CommitOrRollback(tx)
 
-	SQL := "select name,description from category"
+	rows, errLines := tx.Query(ctx, linesSQL)
+	if errLines != nil {
+		return nil, dbError(errLines, "category")
+	}
+	lines, errScan := pgx.CollectRows(rows, pgx.RowTo[int])
+	if errScan != nil {
+		return nil, dbError(errScan, "category")
+	}
+
+	SQL := step(fmt.Sprintf("s.line = $%d", len(args)+1))
+	var rowErrors []*domain.ImportRowError
+	for _, line := range lines {
+		errRow := pgx.BeginFunc(ctx, tx, func(row pgx.Tx) error {
+			_, err := row.Exec(ctx, SQL, append(args, line)...)
+			return err
+		})
+		if errRow == nil {
+			continue
+		}
+		if !isRowError(errRow) {
+			return nil, categoryError(errRow)
+		}
+		rowErrors = append(rowErrors, importRowError(line, errRow))
+	}
+	return rowErrors, nil
+}
+
+// importRowError describes a row rejected by the database, naming the
+// column where the constraint tells which one it is.
+func importRowError(line int, err error) *domain.ImportRowError {
+	rowError := &domain.ImportRowError{Line: line, Message: "invalid value"}
+	var errDomain *domain.Error
+	if errors.As(categoryError(err), &errDomain) {
+		rowError.Message = errDomain.Message
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.ConstraintName == "category_name_key", pgErr.ConstraintName == "category_name_no_slash":
+			rowError.Column = "name"
+		case strings.HasPrefix(pgErr.ConstraintName, "category_parent") || pgErr.ColumnName == "parent_id":
+			rowError.Column = "parent"
+		default:
+			rowError.Column = pgErr.ColumnName
+		}
+	}
+	return rowError
+}
+
+// findImportConflicts reports staged rows whose name already exists, either
+// in category or on an earlier line of the same file.
+func findImportConflicts(ctx context.Context, tx pgx.Tx) ([]*domain.ImportRowError, error) {
+	SQL := `select line from category_import s
+		where exists (select 1 from category c where lower(c.name) = lower(s.name) and c.deleted_at is null)
+		or exists (select 1 from category_import d where lower(d.name) = lower(s.name) and d.line < s.line)
+		order by line asc`
 	rows, err := tx.Query(ctx, SQL)
 	if err != nil {
-		panic(helper.NewHTTPError(500, errors.New("Ooppss")))
+		return nil, dbError(err, "category")
 	}
 	defer rows.Close()
 
-	var categories []*domain.Category
+	var rowErrors []*domain.ImportRowError
 	for rows.Next() {
-		category := &domain.Category{}
-		errScan := rows.Scan(&category.Name, &category.Description)
+		rowError := &domain.ImportRowError{Column: "name", Message: "name already exists"}
+		errScan := rows.Scan(&rowError.Line)
 		if errScan != nil {
-			panic(helper.NewHTTPError(500, errScan))
+			return nil, dbError(errScan, "category")
 		}
-		categories = append(categories, category)
+		rowErrors = append(rowErrors, rowError)
 	}
-	return categories
+	return rowErrors, dbError(rows.Err(), "category")
 }
 
-func (c *CategoryRepoImpl) ExportCsv(ctx context.Context, valueStrings []string, valueArgs []interface{}) error {
-	tx, errBegin := c.DB.Begin(ctx)
-	if errBegin != nil {
-		panic(helper.NewHTTPError(500, errBegin))
+// importParentName is the last segment of a staged parent reference.
+const importParentName = `trim(regexp_replace(s.parent, '^.*/', ''))`
+
+// rejectImportParents removes staged rows whose parent can be found neither
+// in category nor in the file, or whose parent path does not match the
+// existing tree, and reports them.
+func rejectImportParents(ctx context.Context, tx pgx.Tx) ([]*domain.ImportRowError, error) {
+	SQL := fmt.Sprintf(`with recursive %s,
+		rejected as (
+			select s.line, case when p.id is null then 'parent category not found' else 'parent path does not match' end as message
+			from category_import s
+			left join category p on lower(p.name) = lower(%s) and p.deleted_at is null
+			left join paths on path_id = p.id
+			where s.parent <> '' and (
+				(p.id is null and not exists (select 1 from category_import d where lower(d.name) = lower(%s)))
+				or (p.id is not null and s.parent like '%%/%%' and lower(path) <> lower(trim(both '/' from s.parent)))
+			)
+		)
+		delete from category_import s using rejected r where s.line = r.line returning r.line, r.message`,
+		categoryPathsCTE, importParentName, importParentName)
+	rows, err := tx.Query(ctx, SQL)
+	if err != nil {
+		return nil, dbError(err, "category")
 	}
-	defer helper.CommitOrRollback(tx)
+	defer rows.Close()
 
-	SQL := "insert into category (name,description) values "
-	SQL += strings.Join(valueStrings, ",")
-	_, errExec := tx.Exec(ctx, SQL, valueArgs...)
-	if errExec != nil {
-		return errExec
+	var rowErrors []*domain.ImportRowError
+	for rows.Next() {
+		rowError := &domain.ImportRowError{Column: "parent"}
+		errScan := rows.Scan(&rowError.Line, &rowError.Message)
+		if errScan != nil {
+			return nil, dbError(errScan, "category")
+		}
+		rowErrors = append(rowErrors, rowError)
 	}
+	return rowErrors, dbError(rows.Err(), "category")
+}
 
-	return nil
+// conflictClause returns the on conflict handling for an import mode. Rows
+// that are left unchanged are not returned and count as skipped. A row
+// without a parent keeps the current one. Only live categories conflict, a
+// name in the trash can be used again. The inserted row carries the actor in
+// updated_by.
+func conflictClause(mode string) string {
+	switch mode {
+	case domain.ImportModeUpsert:
+		return `on conflict ((lower(name))) where deleted_at is null do update
+			set description = coalesce(nullif(excluded.description, ''), category.description),
+				parent_id = coalesce(excluded.parent_id, category.parent_id),
+				updated_at = now(), updated_by = excluded.updated_by
+			where (category.description, category.parent_id) is distinct from
+				(coalesce(nullif(excluded.description, ''), category.description), coalesce(excluded.parent_id, category.parent_id))`
+	case domain.ImportModeReplace:
+		return `on conflict ((lower(name))) where deleted_at is null do update
+			set name = excluded.name, description = excluded.description,
+				parent_id = coalesce(excluded.parent_id, category.parent_id),
+				updated_at = now(), updated_by = excluded.updated_by
+			where (category.name, category.description, category.parent_id) is distinct from
+				(excluded.name, excluded.description, coalesce(excluded.parent_id, category.parent_id))`
+	default:
+		return "on conflict do nothing"
+	}
 }
 
-// Delete implements CategoryRepo.
+// Delete implements CategoryRepo. The category is moved to the trash; it
+// must not have live children. Callers lock the row first, see
+// FindByIdForUpdate, so no child can be added in between.
 func (c *CategoryRepoImpl) Delete(ctx context.Context, categoryId int) error {
-	tx, errBegin := c.DB.Begin(ctx)
-	if errBegin != nil {
-		panic(helper.NewHTTPError(500, errBegin))
+	return c.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		db := c.TxManager.Querier(ctx)
+
+		var hasChildren bool
+		SQL := "select exists (select 1 from category where parent_id = $1 and deleted_at is null)"
+		errChild := db.QueryRow(ctx, SQL, categoryId).Scan(&hasChildren)
+		if errChild != nil {
+			return dbError(errChild, "category")
+		}
+		if hasChildren {
+			return domain.NewError(domain.ErrConflict, "category still has child categories", nil)
+		}
+
+		before, errFind := c.FindByIdForUpdate(ctx, categoryId)
+		if errFind != nil {
+			return errFind
+		}
+
+		SQL = "update category set deleted_at = now(), updated_at = now(), updated_by = $2 where id = $1 returning " + categoryColumns
+		after, errEx := scanCategory(db.QueryRow(ctx, SQL, categoryId, helper.Actor(ctx)))
+		if errEx != nil {
+			return dbError(errEx, "category")
+		}
+		return c.writeRevision(ctx, domain.RevisionDelete, before, after)
+	})
+}
+
+// Restore implements CategoryRepo. A live category may have taken the name
+// in the meantime, which is reported as a conflict.
+func (c *CategoryRepoImpl) Restore(ctx context.Context, categoryId int) (*domain.Category, error) {
+	var after *domain.Category
+	err := c.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		before, errFind := c.FindTrashedByIdForUpdate(ctx, categoryId)
+		if errFind != nil {
+			return errFind
+		}
+
+		SQL := "update category set deleted_at = null, updated_at = now(), updated_by = $2 where id = $1 returning " + categoryColumns
+		var errUp error
+		after, errUp = scanCategory(c.TxManager.Querier(ctx).QueryRow(ctx, SQL, categoryId, helper.Actor(ctx)))
+		if errUp != nil {
+			return categoryError(errUp)
+		}
+		return c.writeRevision(ctx, domain.RevisionRestore, before, after)
+	})
+	if err != nil {
+		return nil, err
 	}
-	defer helper.CommitOrRollback(tx)
+	return after, nil
+}
 
-	SQL := "delete from category where id = $1 returning id"
-	_, errEx := tx.Exec(ctx, SQL, categoryId)
-	if errEx != nil {
-		return errEx
+// Purge implements CategoryRepo. It removes the categories trashed before
+// before for good and returns how many were removed. A child is always
+// trashed before its parent, so each round removes the leaves until none
+// are left. All rounds run in one transaction and every removed category
+// gets a purge revision, so its history ends with who removed it.
+func (c *CategoryRepoImpl) Purge(ctx context.Context, before time.Time) (int64, error) {
+	SQL := fmt.Sprintf(`with purged as (
+			delete from category t where deleted_at < $1
+			and not exists (select 1 from category c where c.parent_id = t.id)
+			returning t.*
+		)
+		insert into category_revision (category_id, rev, action, old_values, new_values, actor, request_id)
+		select p.id, p.version + 1, '%s', %s, %[2]s, $2, nullif($3, '') from purged p`,
+		domain.RevisionPurge, snapshotSQL("p"))
+
+	var total int64
+	err := c.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		for {
+			tag, err := c.TxManager.Querier(ctx).Exec(ctx, SQL, before, helper.Actor(ctx), helper.RequestID(ctx))
+			if err != nil {
+				return dbError(err, "category")
+			}
+			if tag.RowsAffected() == 0 {
+				return nil
+			}
+			total += tag.RowsAffected()
+		}
+	})
+	if err != nil {
+		return 0, err
 	}
+	return total, nil
+}
 
-	return nil
+var categorySortColumns = map[string]sortColumn{
+	"id":          {Expr: "id", Cast: "integer"},
+	"name":        {Expr: "name", Cast: "text"},
+	"description": {Expr: "coalesce(description, '')", Cast: "text"},
+	"createdAt":   {Expr: "created_at", Cast: "timestamptz"},
+	"updatedAt":   {Expr: "updated_at", Cast: "timestamptz"},
+	"createdBy":   {Expr: "coalesce(created_by, '')", Cast: "text"},
+	"updatedBy":   {Expr: "coalesce(updated_by, '')", Cast: "text"},
 }
 
-// FindAll implements CategoryRepo.
-func (c *CategoryRepoImpl) FindAll(ctx context.Context, params *request.CategoryQueryParams) []*domain.Category {
-	tx, errBegin := c.DB.Begin(ctx)
-	if errBegin != nil {
-		panic(helper.NewHTTPError(500, errBegin))
+var categoryFilterColumns = map[string]filterColumn{
+	"id":          {Expr: "id", Cast: "integer", Operators: numberOperators},
+	"name":        {Expr: "name", Cast: "text", Operators: textOperators},
+	"description": {Expr: "description", Cast: "text", Operators: append(textOperators, domain.FilterIsNull)},
+	"parentId":    {Expr: "parent_id", Cast: "integer", Operators: append(numberOperators, domain.FilterIsNull)},
+	"createdAt":   {Expr: "created_at", Cast: "timestamptz", Operators: timeOperators},
+	"updatedAt":   {Expr: "updated_at", Cast: "timestamptz", Operators: timeOperators},
+	"createdBy":   {Expr: "created_by", Cast: "text", Operators: append(textOperators, domain.FilterIsNull)},
+	"updatedBy":   {Expr: "updated_by", Cast: "text", Operators: append(textOperators, domain.FilterIsNull)},
+}
+
+// ValidateQuery implements CategoryRepo.
+func (c *CategoryRepoImpl) ValidateQuery(filters []domain.Filter, sort []domain.SortKey) error {
+	return checkListQuery(filters, sort, categoryFilterColumns, categorySortColumns)
+}
+
+// FindAll implements CategoryRepo. One row more than the limit is read to
+// tell whether another page follows.
+func (c *CategoryRepoImpl) FindAll(ctx context.Context, query *domain.PageQuery) (*domain.CategoryPage, error) {
+	errCheck := c.ValidateQuery(query.Filters, query.Sort)
+	if errCheck != nil {
+		return nil, errCheck
 	}
-	defer helper.CommitOrRollback(tx)
-	SQL := "select id,name,description from category where id > $1 order by id asc limit $2"
-	rows, err := tx.Query(ctx, SQL, params.Id, params.Limit)
-	if err != nil {
-		panic(helper.NewHTTPError(500, errors.New("Ooppss")))
+
+	db := c.TxManager.Querier(ctx)
+	builder := &queryBuilder{conditions: []string{"deleted_at is null"}}
+	if query.Trashed {
+		builder.conditions = []string{"deleted_at is not null"}
+	}
+	builder.filter(query.Filters, categoryFilterColumns)
+	page := &domain.CategoryPage{}
+
+	if query.IncludeTotal {
+		var total int64
+		errCount := db.QueryRow(ctx, "select count(*) from category"+builder.where(), builder.args...).Scan(&total)
+		if errCount != nil {
+			return nil, dbError(errCount, "category")
+		}
+		page.Total = &total
+	}
+
+	if query.After != nil {
+		builder.keyset(query.Sort, categorySortColumns, query.After, query.Backward)
+	}
+	SQL := "select " + categoryColumns + " from category" + builder.where() +
+		orderBy(query.Sort, categorySortColumns, query.Backward) + " limit " + builder.arg(query.Limit+1)
+	rows, errQuery := db.Query(ctx, SQL, builder.args...)
+	if errQuery != nil {
+		return nil, dbError(errQuery, "category")
 	}
 
 	defer rows.Close()
 
-	var categories []*domain.Category
+	for rows.Next() {
+		category, errScan := scanCategory(rows)
+		if errScan != nil {
+			return nil, dbError(errScan, "category")
+		}
+		page.Categories = append(page.Categories, category)
+	}
+	if rows.Err() != nil {
+		return nil, dbError(rows.Err(), "category")
+	}
+
+	if len(page.Categories) > query.Limit {
+		page.HasMore = true
+		page.Categories = page.Categories[:query.Limit]
+	}
+	if query.Backward {
+		slices.Reverse(page.Categories)
+	}
+	return page, nil
+}
 
+// Search implements CategoryRepo. A row matches on whole words in name or
+// description, on a name similar to text (typos) or on a name starting with
+// text (autocomplete). Word matches weigh most, name matches twice as much
+// as description ones through the tsvector weights.
+func (c *CategoryRepoImpl) Search(ctx context.Context, text string, limit int) ([]*domain.CategorySearchHit, error) {
+	SQL := `with q as (
+			select websearch_to_tsquery('simple', $1) as ts, $1::text as raw, $2::text as prefix
+		)
+		select id, name, coalesce(description, ''), parent_id,
+			(ts_rank(search, q.ts) * 2 + similarity(name, q.raw) + case when name ilike q.prefix then 0.5 else 0 end)::float8 as score,
+			ts_headline('simple', translate(name || ' ' || coalesce(description, ''), chr(2) || chr(3), ''), q.ts,
+				'StartSel="' || chr(2) || '", StopSel="' || chr(3) || '", MaxWords=20, MinWords=5') as snippet
+		from category, q
+		where deleted_at is null and (search @@ q.ts or name % q.raw or name ilike q.prefix)
+		order by score desc, id asc
+		limit $3`
+	rows, err := c.TxManager.Querier(ctx).Query(ctx, SQL, text, escapeLike(text)+"%", limit)
+	if err != nil {
+		return nil, dbError(err, "category")
+	}
+	defer rows.Close()
+
+	var hits []*domain.CategorySearchHit
 	for rows.Next() {
-		category := &domain.Category{}
-		errScan := rows.Scan(&category.Id, &category.Name, &category.Description)
+		hit := &domain.CategorySearchHit{Category: &domain.Category{}}
+		errScan := rows.Scan(&hit.Category.Id, &hit.Category.Name, &hit.Category.Description, &hit.Category.ParentId, &hit.Score, &hit.Snippet)
 		if errScan != nil {
-			panic(helper.NewHTTPError(500, errScan))
+			return nil, dbError(errScan, "category")
 		}
-		categories = append(categories, category)
+		hit.Snippet = highlightSnippet(hit.Snippet)
+		hits = append(hits, hit)
 	}
-	return categories
+	return hits, dbError(rows.Err(), "category")
+}
+
+// highlightSnippet escapes the user text of a ts_headline snippet for HTML
+// and only then turns the chr(2)/chr(3) markers Search selects with into
+// <mark> tags. Search strips both markers from the text beforehand, so every
+// one left comes from ts_headline.
+func highlightSnippet(snippet string) string {
+	return strings.NewReplacer("\x02", "<mark>", "\x03", "</mark>").Replace(html.EscapeString(snippet))
+}
+
+// escapeLike escapes the wildcards of a like pattern.
+func escapeLike(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text)
 }
 
 // FindById implements CategoryRepo.
-func (c *CategoryRepoImpl) FindById(ctx context.Context, categoryId int) *domain.Category {
-	tx, errBegin := c.DB.Begin(ctx)
-	if errBegin != nil {
-		panic(helper.NewHTTPError(500, errBegin))
+func (c *CategoryRepoImpl) FindById(ctx context.Context, categoryId int) (*domain.Category, error) {
+	return c.findOne(ctx, "select "+categoryColumns+" from category where id = $1 and deleted_at is null", categoryId)
+}
+
+// FindByIdForUpdate implements CategoryRepo. The row stays locked until the
+// transaction in ctx ends, so it must be called within one.
+func (c *CategoryRepoImpl) FindByIdForUpdate(ctx context.Context, categoryId int) (*domain.Category, error) {
+	return c.findOne(ctx, "select "+categoryColumns+" from category where id = $1 and deleted_at is null for update", categoryId)
+}
+
+// FindTrashedByIdForUpdate implements CategoryRepo. It is FindByIdForUpdate
+// for a category in the trash.
+func (c *CategoryRepoImpl) FindTrashedByIdForUpdate(ctx context.Context, categoryId int) (*domain.Category, error) {
+	category, err := c.findOne(ctx, "select "+categoryColumns+" from category where id = $1 and deleted_at is not null for update", categoryId)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.NewError(domain.ErrNotFound, "category not found in trash", nil)
 	}
-	defer helper.CommitOrRollback(tx)
+	return category, err
+}
 
-	SQL := "select id, name, description from category where id = $1"
-	category := &domain.Category{}
-	errQuery := tx.QueryRow(ctx, SQL, categoryId).Scan(&category.Id, &category.Name, &category.Description)
+func (c *CategoryRepoImpl) findOne(ctx context.Context, SQL string, args ...any) (*domain.Category, error) {
+	category, errQuery := scanCategory(c.TxManager.Querier(ctx).QueryRow(ctx, SQL, args...))
 	if errQuery != nil {
-		panic(helper.NewHTTPError(404, errors.New("category not found")))
+		return nil, dbError(errQuery, "category")
 	}
 
-	return category
+	return category, nil
 }
 
 // Insert implements CategoryRepo.
-func (c *CategoryRepoImpl) Insert(ctx context.Context, category *domain.Category) *domain.Category {
-	tx, errBegin := c.DB.Begin(ctx)
-	if errBegin != nil {
-		panic(helper.NewHTTPError(500, errBegin))
-	}
-	defer helper.CommitOrRollback(tx)
-
-	SQL := "insert into category(name, description) values($1, $2) returning id"
-	err := tx.QueryRow(ctx, SQL, category.Name, category.Description).Scan(&category.Id)
+func (c *CategoryRepoImpl) Insert(ctx context.Context, category *domain.Category) (*domain.Category, error) {
+	var result *domain.Category
+	err := c.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		SQL := "insert into category(name, description, parent_id, created_by, updated_by) values($1, $2, $3, $4, $4) returning " + categoryColumns
+		var errQuery error
+		result, errQuery = scanCategory(c.TxManager.Querier(ctx).QueryRow(ctx, SQL, category.Name, category.Description, category.ParentId, helper.Actor(ctx)))
+		if errQuery != nil {
+			return categoryError(errQuery)
+		}
+		return c.writeRevision(ctx, domain.RevisionInsert, nil, result)
+	})
 	if err != nil {
-		panic(helper.NewHTTPError(500, err))
+		return nil, err
 	}
 
-	return category
+	return result, nil
 }
 
 // Update implements CategoryRepo.
-func (c *CategoryRepoImpl) Update(ctx context.Context, category *domain.Category) *domain.Category {
-	tx, errBegin := c.DB.Begin(ctx)
-	if errBegin != nil {
-		panic(helper.NewHTTPError(500, errBegin))
-	}
-	defer helper.CommitOrRollback(tx)
+func (c *CategoryRepoImpl) Update(ctx context.Context, category *domain.Category) (*domain.Category, error) {
+	var result *domain.Category
+	err := c.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		before, errFind := c.FindByIdForUpdate(ctx, category.Id)
+		if errFind != nil {
+			return errFind
+		}
 
-	SQL := "update category set name = $1, description = $2 where id = $3 returning id,name,description"
-	err := tx.QueryRow(ctx, SQL, category.Name, category.Description, category.Id).Scan(&category.Id, &category.Name, &category.Description)
+		SQL := "update category set name = $1, description = $2, updated_at = now(), updated_by = $4 where id = $3 returning " + categoryColumns
+		var errQuery error
+		result, errQuery = scanCategory(c.TxManager.Querier(ctx).QueryRow(ctx, SQL, category.Name, category.Description, category.Id, helper.Actor(ctx)))
+		if errQuery != nil {
+			return categoryError(errQuery)
+		}
+		return c.writeRevision(ctx, domain.RevisionUpdate, before, result)
+	})
 	if err != nil {
-		panic(helper.NewHTTPError(500, errors.New("Ooppss")))
+		return nil, err
 	}
-	return category
+	return result, nil
 }
 
-// ExportCsvGo implements CategoryRepo.
-func (c *CategoryRepoImpl) ExportCsvGo(ctx context.Context, request *domain.Category) error {
-	tx, errBegin := c.DB.Begin(ctx)
-	if errBegin != nil {
-		panic(helper.NewHTTPError(500, errBegin))
+// categoryError is dbError with the messages for a duplicate name and for
+// an invalid parent.
+func categoryError(err error) error {
+	switch {
+	case helper.IsUniqueViolation(err):
+		return domain.NewError(domain.ErrConflict, "category name already exists", err)
+	case helper.IsForeignKeyViolation(err):
+		return domain.NewError(domain.ErrValidation, "parent category not found", err)
+	case helper.IsCheckViolation(err) && constraintName(err) == "category_name_no_slash":
+		return domain.NewError(domain.ErrValidation, "category name cannot contain /", err)
+	case helper.IsCheckViolation(err):
+		return domain.NewError(domain.ErrValidation, "category cannot be moved under itself or its own subtree", err)
+	default:
+		return dbError(err, "category")
 	}
-	defer helper.CommitOrRollback(tx)
+}
 
+// ImportRow implements CategoryRepo. It returns what happened to the row
+// and the category it wrote, or the live category that made it skip. A
+// parent that does not exist yet is reported as ErrNotFound; it may still
+// be created by another row of the same upload.
+func (c *CategoryRepoImpl) ImportRow(ctx context.Context, row *domain.ImportRow, mode string) (string, *domain.Category, error) {
+	var parentId *int
+	if row.Parent != "" {
+		parent, errParent := c.FindByPath(ctx, row.Parent)
+		if errParent != nil {
+			return "", nil, errParent
+		}
+		parentId = &parent.Id
+	}
+
+	actor := helper.Actor(ctx)
 	data := []interface{}{
-		request.Name,
-		request.Description,
+		row.Category.Name,
+		row.Category.Description,
+		parentId,
+		actor,
+		actor,
 	}
 
-	SQL := fmt.Sprintf("insert into category (name,description) values (%s)",
+	clause := ""
+	if mode != domain.ImportModeInsert {
+		clause = conflictClause(mode)
+	}
+
+	SQL := fmt.Sprintf("insert into category (name,description,parent_id,created_by,updated_by) values (%s) %s returning (xmax = 0), %s",
 		generateDollarsMark(data),
+		clause,
+		categoryColumns,
 	)
 
-	_, errExec := tx.Exec(ctx, SQL, data...)
-	if errExec != nil {
-		panic(helper.NewHTTPError(500, errors.New("exec")))
-	}
+	outcome := domain.RowSkipped
+	var result *domain.Category
+	err := c.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		// row lama dikunci dulu supaya isi sebelumnya bisa dicatat di revision
+		before, errFind := c.findOne(ctx, "select "+categoryColumns+" from category where lower(name) = lower($1) and deleted_at is null for update", row.Category.Name)
+		if errFind != nil && !errors.Is(errFind, domain.ErrNotFound) {
+			return errFind
+		}
 
-	return nil
+		var inserted bool
+		after := &domain.Category{}
+		errExec := c.TxManager.Querier(ctx).QueryRow(ctx, SQL, data...).Scan(append([]any{&inserted}, categoryTargets(after)...)...)
+		if errors.Is(errExec, pgx.ErrNoRows) {
+			result = before
+			return nil
+		}
+		if errExec != nil {
+			return categoryError(errExec)
+		}
+
+		result = after
+		if inserted {
+			outcome = domain.RowInserted
+			return c.writeRevision(ctx, domain.RevisionInsert, nil, after)
+		}
+		outcome = domain.RowUpdated
+		return c.writeRevision(ctx, domain.RevisionUpdate, before, after)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return outcome, result, nil
 }
 
 func generateDollarsMark(data []interface{}) string {
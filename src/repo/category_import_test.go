@@ -0,0 +1,96 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/daint23/gofiberpg/src/database"
+	"github.com/daint23/gofiberpg/src/domain"
+	"github.com/daint23/gofiberpg/src/migrations"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testTxManager connects to TEST_DATABASE_URL and migrates it. Tests run
+// their work in a transaction and roll it back.
+func testTxManager(t *testing.T) database.TxManager {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	ctx := context.Background()
+	db, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+
+	migrator, errMigrator := database.NewMigrator(db, migrations.FS)
+	if errMigrator != nil {
+		t.Fatal(errMigrator)
+	}
+	_, errUp := migrator.Up(ctx, 0)
+	if errUp != nil {
+		t.Fatal(errUp)
+	}
+	return database.NewTxManager(db)
+}
+
+var errRollback = errors.New("rollback")
+
+func TestExportCsvImportedRowsStartAtRevOne(t *testing.T) {
+	txManager := testTxManager(t)
+	categoryRepo := NewCategoryRepo(txManager)
+
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	parent, child := "Import Parent "+suffix, "Import Child "+suffix
+
+	for _, mode := range []string{domain.ImportModeInsert, domain.ImportModeUpsert, domain.ImportModeReplace} {
+		t.Run(mode, func(t *testing.T) {
+			// t.Fatal di dalam fn akan commit, jadi error dikembalikan supaya selalu rollback
+			err := txManager.WithinTx(context.Background(), func(ctx context.Context) error {
+				// child sebelum parent, parent baru dibuat di file yang sama
+				rows := pgx.CopyFromRows([][]any{
+					{1, child, "", parent},
+					{2, parent, "", ""},
+				})
+				result, errImport := categoryRepo.ExportCsv(ctx, rows, &domain.ImportOptions{Mode: mode})
+				if errImport != nil {
+					return errImport
+				}
+				if result.Inserted != 2 || len(result.Errors) > 0 {
+					t.Errorf("inserted %d with errors %v, want 2 without errors", result.Inserted, result.Errors)
+				}
+
+				for _, name := range []string{parent, child} {
+					SQL := `select c.version, array_agg(r.rev order by r.rev), bool_and(c.parent_id is not null or c.name = $2)
+						from category c join category_revision r on r.category_id = c.id
+						where c.name = $1 group by c.version`
+					var version int
+					var revs []int
+					var linked bool
+					errRev := txManager.Querier(ctx).QueryRow(ctx, SQL, name, parent).Scan(&version, &revs, &linked)
+					if errRev != nil {
+						return errRev
+					}
+					if version != 1 || len(revs) != 1 || revs[0] != 1 {
+						t.Errorf("%s: version %d with revs %v, want version 1 with revs [1]", name, version, revs)
+					}
+					if !linked {
+						t.Errorf("%s: parent not linked", name)
+					}
+				}
+				return errRollback
+			})
+			if !errors.Is(err, errRollback) {
+				t.Fatal(err)
+			}
+		})
+	}
+}
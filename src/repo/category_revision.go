@@ -0,0 +1,140 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/daint23/gofiberpg/src/domain"
+	"github.com/daint23/gofiberpg/src/helper"
+)
+
+// snapshotValues is the jsonb form of domain.CategorySnapshot, the same
+// object snapshotSQL builds in SQL.
+type snapshotValues struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	ParentId    *int       `json:"parentId"`
+	DeletedAt   *time.Time `json:"deletedAt"`
+}
+
+// snapshotSQL builds the jsonb snapshot of the category row alias.
+func snapshotSQL(alias string) string {
+	return fmt.Sprintf("jsonb_build_object('name', %[1]s.name, 'description', coalesce(%[1]s.description, ''), "+
+		"'parentId', %[1]s.parent_id, 'deletedAt', %[1]s.deleted_at)", alias)
+}
+
+func toSnapshotValues(category *domain.Category) *snapshotValues {
+	return &snapshotValues{
+		Name:        category.Name,
+		Description: category.Description,
+		ParentId:    category.ParentId,
+		DeletedAt:   category.DeletedAt,
+	}
+}
+
+func (v *snapshotValues) toDomain() *domain.CategorySnapshot {
+	if v == nil {
+		return nil
+	}
+	return &domain.CategorySnapshot{
+		Name:        v.Name,
+		Description: v.Description,
+		ParentId:    v.ParentId,
+		DeletedAt:   v.DeletedAt,
+	}
+}
+
+// writeRevision records a change from before to after; before is nil for
+// an insert. It must run in the transaction that made the change, revisions
+// are never updated later.
+func (c *CategoryRepoImpl) writeRevision(ctx context.Context, action string, before *domain.Category, after *domain.Category) error {
+	var oldValues []byte
+	if before != nil {
+		var errOld error
+		oldValues, errOld = json.Marshal(toSnapshotValues(before))
+		if errOld != nil {
+			return errOld
+		}
+	}
+	newValues, errNew := json.Marshal(toSnapshotValues(after))
+	if errNew != nil {
+		return errNew
+	}
+
+	SQL := `insert into category_revision (category_id, rev, action, old_values, new_values, actor, request_id)
+		values ($1, $2, $3, $4, $5, $6, nullif($7, ''))`
+	_, err := c.TxManager.Querier(ctx).Exec(ctx, SQL, after.Id, after.Version, action, oldValues, newValues,
+		helper.Actor(ctx), helper.RequestID(ctx))
+	return dbError(err, "category revision")
+}
+
+const revisionColumns = "category_id,rev,action,old_values,new_values,actor,coalesce(request_id,''),created_at"
+
+func scanRevision(row rowScanner) (*domain.CategoryRevision, error) {
+	revision := &domain.CategoryRevision{}
+	var oldValues, newValues *snapshotValues
+	err := row.Scan(&revision.CategoryId, &revision.Rev, &revision.Action, &oldValues, &newValues,
+		&revision.Actor, &revision.RequestId, &revision.CreatedAt)
+	revision.Old = oldValues.toDomain()
+	revision.New = newValues.toDomain()
+	return revision, err
+}
+
+var revisionSortColumns = map[string]sortColumn{
+	"rev": {Expr: "rev", Cast: "integer"},
+}
+
+// FindRevisions implements CategoryRepo. Sort may only use rev.
+func (c *CategoryRepoImpl) FindRevisions(ctx context.Context, categoryId int, query *domain.PageQuery) (*domain.CategoryRevisionPage, error) {
+	errCheck := checkListQuery(nil, query.Sort, nil, revisionSortColumns)
+	if errCheck != nil {
+		return nil, errCheck
+	}
+
+	builder := &queryBuilder{}
+	builder.conditions = append(builder.conditions, "category_id = "+builder.arg(categoryId))
+	if query.After != nil {
+		builder.keyset(query.Sort, revisionSortColumns, query.After, query.Backward)
+	}
+	SQL := "select " + revisionColumns + " from category_revision" + builder.where() +
+		orderBy(query.Sort, revisionSortColumns, query.Backward) + " limit " + builder.arg(query.Limit+1)
+	rows, err := c.TxManager.Querier(ctx).Query(ctx, SQL, builder.args...)
+	if err != nil {
+		return nil, dbError(err, "category revision")
+	}
+	defer rows.Close()
+
+	page := &domain.CategoryRevisionPage{}
+	for rows.Next() {
+		revision, errScan := scanRevision(rows)
+		if errScan != nil {
+			return nil, dbError(errScan, "category revision")
+		}
+		page.Revisions = append(page.Revisions, revision)
+	}
+	if rows.Err() != nil {
+		return nil, dbError(rows.Err(), "category revision")
+	}
+
+	if len(page.Revisions) > query.Limit {
+		page.HasMore = true
+		page.Revisions = page.Revisions[:query.Limit]
+	}
+	if query.Backward {
+		slices.Reverse(page.Revisions)
+	}
+	return page, nil
+}
+
+// FindRevision implements CategoryRepo.
+func (c *CategoryRepoImpl) FindRevision(ctx context.Context, categoryId int, rev int) (*domain.CategoryRevision, error) {
+	SQL := "select " + revisionColumns + " from category_revision where category_id = $1 and rev = $2"
+	revision, err := scanRevision(c.TxManager.Querier(ctx).QueryRow(ctx, SQL, categoryId, rev))
+	if err != nil {
+		return nil, dbError(err, "category revision")
+	}
+	return revision, nil
+}
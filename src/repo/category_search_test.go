@@ -0,0 +1,11 @@
+package repo
+
+import "testing"
+
+func TestHighlightSnippetEscapesUserText(t *testing.T) {
+	got := highlightSnippet("<script>alert(1)</script> \x02books\x03 & more")
+	want := "&lt;script&gt;alert(1)&lt;/script&gt; <mark>books</mark> &amp; more"
+	if got != want {
+		t.Errorf("highlightSnippet() = %q, want %q", got, want)
+	}
+}
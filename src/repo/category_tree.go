@@ -0,0 +1,118 @@
+package repo
+
+import (
+	"context"
+	"strings"
+
+	"github.com/daint23/gofiberpg/src/domain"
+	"github.com/daint23/gofiberpg/src/helper"
+)
+
+// categoryPathsCTE lists the full path of every category, e.g.
+// "Shoes/Running", as paths(path_id, path). It is meant to follow
+// "with recursive".
+const categoryPathsCTE = `paths (path_id, path) as (
+		select id, name::text from category where parent_id is null and deleted_at is null
+		union all
+		select c.id, p.path || '/' || c.name from category c join paths p on c.parent_id = p.path_id
+		where c.deleted_at is null
+	)`
+
+// FindSubtrees implements CategoryRepo. The roots and their descendants are
+// returned level by level; depth limits how many levels below the roots are
+// read, 0 reads all of them.
+func (c *CategoryRepoImpl) FindSubtrees(ctx context.Context, rootIds []int, depth int) ([]*domain.Category, error) {
+	SQL := `with recursive tree as (
+			select id, 0 as depth from category where id = any($1::integer[]) and deleted_at is null
+			union all
+			select c.id, t.depth + 1 from category c join tree t on c.parent_id = t.id
+			where c.deleted_at is null and ($2 = 0 or t.depth < $2)
+		)
+		select ` + categoryColumns + ` from tree join category using (id) order by depth, name, id`
+	return c.findCategories(ctx, SQL, rootIds, depth)
+}
+
+// FindAncestors implements CategoryRepo. The root comes first and the
+// direct parent last; the category itself is not included.
+func (c *CategoryRepoImpl) FindAncestors(ctx context.Context, categoryId int) ([]*domain.Category, error) {
+	SQL := `with recursive ancestors as (
+			select p.id, p.parent_id as next_id, 1 as depth
+			from category c join category p on p.id = c.parent_id where c.id = $1
+			union all
+			select p.id, p.parent_id, a.depth + 1
+			from category p join ancestors a on p.id = a.next_id
+		)
+		select ` + categoryColumns + ` from ancestors join category using (id) order by depth desc`
+	return c.findCategories(ctx, SQL, categoryId)
+}
+
+func (c *CategoryRepoImpl) findCategories(ctx context.Context, SQL string, args ...any) ([]*domain.Category, error) {
+	rows, err := c.TxManager.Querier(ctx).Query(ctx, SQL, args...)
+	if err != nil {
+		return nil, dbError(err, "category")
+	}
+	defer rows.Close()
+
+	var categories []*domain.Category
+	for rows.Next() {
+		category, errScan := scanCategory(rows)
+		if errScan != nil {
+			return nil, dbError(errScan, "category")
+		}
+		categories = append(categories, category)
+	}
+	return categories, dbError(rows.Err(), "category")
+}
+
+// FindByPath implements CategoryRepo. path is a name or a path such as
+// "Shoes/Running". Names are unique, so the last segment finds the
+// category and the rest must match its ancestors.
+func (c *CategoryRepoImpl) FindByPath(ctx context.Context, path string) (*domain.Category, error) {
+	segments := strings.Split(path, "/")
+	SQL := `with recursive up as (
+			select id, name, parent_id, 0 as depth from category where lower(name) = lower($1) and deleted_at is null
+			union all
+			select c.id, c.name, c.parent_id, u.depth + 1 from category c join up u on c.id = u.parent_id
+		)
+		select c.id, c.name, coalesce(c.description, ''), c.parent_id,
+			(select string_agg(name, '/' order by depth desc) from up)
+		from category c where lower(c.name) = lower($1) and c.deleted_at is null`
+
+	var fullPath string
+	category := &domain.Category{}
+	err := c.TxManager.Querier(ctx).QueryRow(ctx, SQL, segments[len(segments)-1]).
+		Scan(&category.Id, &category.Name, &category.Description, &category.ParentId, &fullPath)
+	if err != nil {
+		return nil, dbError(err, "parent category")
+	}
+
+	if len(segments) > 1 && !strings.EqualFold(fullPath, path) {
+		return nil, domain.NewError(domain.ErrValidation, "parent path does not match, the category is at "+fullPath, nil)
+	}
+	return category, nil
+}
+
+// Move implements CategoryRepo. A nil parentId makes the category a root.
+// Moving a category under itself or its own subtree is rejected by the
+// category_parent_check trigger.
+func (c *CategoryRepoImpl) Move(ctx context.Context, categoryId int, parentId *int) (*domain.Category, error) {
+	var after *domain.Category
+	err := c.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		before, errFind := c.FindByIdForUpdate(ctx, categoryId)
+		if errFind != nil {
+			return errFind
+		}
+
+		SQL := "update category set parent_id = $1, updated_at = now(), updated_by = $3 where id = $2 returning " + categoryColumns
+		var errUp error
+		after, errUp = scanCategory(c.TxManager.Querier(ctx).QueryRow(ctx, SQL, parentId, categoryId, helper.Actor(ctx)))
+		if errUp != nil {
+			return categoryError(errUp)
+		}
+		return c.writeRevision(ctx, domain.RevisionMove, before, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return after, nil
+}
@@ -0,0 +1,30 @@
+package repo
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/daint23/gofiberpg/src/helper"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// dbError is helper.DBError, kept short for the many call sites here.
+func dbError(err error, entity string) error {
+	return helper.DBError(err, entity)
+}
+
+// isRowError reports data exceptions and integrity violations, errors caused
+// by the values of one row rather than by the database.
+func isRowError(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (strings.HasPrefix(pgErr.Code, "22") || strings.HasPrefix(pgErr.Code, "23"))
+}
+
+// constraintName returns the constraint a pgx error names, if any.
+func constraintName(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.ConstraintName
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/daint23/gofiberpg/src/database"
+	"github.com/daint23/gofiberpg/src/domain"
+	"github.com/daint23/gofiberpg/src/http/request"
+	"github.com/jackc/pgx/v5"
+)
+
+type ImportFailureRepo interface {
+	InsertAll(ctx context.Context, failures []*domain.ImportFailure) error
+	FindById(ctx context.Context, failureId int) (*domain.ImportFailure, error)
+	FindAll(ctx context.Context, params *request.ImportFailureQueryParams) ([]*domain.ImportFailure, error)
+	MarkReplayed(ctx context.Context, failureId int) error
+}
+
+type ImportFailureRepoImpl struct {
+	TxManager database.TxManager
+}
+
+func NewImportFailureRepo(txManager database.TxManager) ImportFailureRepo {
+	return &ImportFailureRepoImpl{
+		TxManager: txManager,
+	}
+}
+
+const importFailureColumns = "id,coalesce(job_id,0),line,name,coalesce(description,''),coalesce(parent,''),mode,error,attempts,created_at,replayed_at"
+
+func scanImportFailure(row rowScanner) (*domain.ImportFailure, error) {
+	failure := &domain.ImportFailure{}
+	err := row.Scan(&failure.Id, &failure.JobId, &failure.Line, &failure.Name, &failure.Description, &failure.Parent, &failure.Mode, &failure.Error, &failure.Attempts, &failure.CreatedAt, &failure.ReplayedAt)
+	return failure, err
+}
+
+// InsertAll implements ImportFailureRepo.
+func (i *ImportFailureRepoImpl) InsertAll(ctx context.Context, failures []*domain.ImportFailure) error {
+	if len(failures) == 0 {
+		return nil
+	}
+
+	rows := pgx.CopyFromSlice(len(failures), func(idx int) ([]any, error) {
+		failure := failures[idx]
+		return []any{failure.JobId, failure.Line, failure.Name, failure.Description, failure.Parent, failure.Mode, failure.Error, failure.Attempts}, nil
+	})
+	_, err := i.TxManager.Querier(ctx).CopyFrom(ctx, pgx.Identifier{"category_import_failures"}, []string{"job_id", "line", "name", "description", "parent", "mode", "error", "attempts"}, rows)
+	return dbError(err, "import failure")
+}
+
+// FindById implements ImportFailureRepo.
+func (i *ImportFailureRepoImpl) FindById(ctx context.Context, failureId int) (*domain.ImportFailure, error) {
+	SQL := "select " + importFailureColumns + " from category_import_failures where id = $1"
+	failure, err := scanImportFailure(i.TxManager.Querier(ctx).QueryRow(ctx, SQL, failureId))
+	if err != nil {
+		return nil, dbError(err, "import failure")
+	}
+
+	return failure, nil
+}
+
+// FindAll implements ImportFailureRepo.
+func (i *ImportFailureRepoImpl) FindAll(ctx context.Context, params *request.ImportFailureQueryParams) ([]*domain.ImportFailure, error) {
+	SQL := "select " + importFailureColumns + ` from category_import_failures
+		where ($1 = 0 or job_id = $1) and (not $2 or replayed_at is null)
+		order by id desc limit $3`
+	rows, err := i.TxManager.Querier(ctx).Query(ctx, SQL, params.JobId, params.Pending, params.Limit)
+	if err != nil {
+		return nil, dbError(err, "import failure")
+	}
+	defer rows.Close()
+
+	var failures []*domain.ImportFailure
+	for rows.Next() {
+		failure, errScan := scanImportFailure(rows)
+		if errScan != nil {
+			return nil, dbError(errScan, "import failure")
+		}
+		failures = append(failures, failure)
+	}
+	return failures, dbError(rows.Err(), "import failure")
+}
+
+// MarkReplayed implements ImportFailureRepo.
+func (i *ImportFailureRepoImpl) MarkReplayed(ctx context.Context, failureId int) error {
+	SQL := "update category_import_failures set replayed_at = now() where id = $1 and replayed_at is null"
+	tag, err := i.TxManager.Querier(ctx).Exec(ctx, SQL, failureId)
+	if err != nil {
+		return dbError(err, "import failure")
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.NewError(domain.ErrConflict, "import failure already replayed", nil)
+	}
+	return nil
+}
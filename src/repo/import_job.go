@@ -0,0 +1,146 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/daint23/gofiberpg/src/database"
+	"github.com/daint23/gofiberpg/src/domain"
+	"github.com/daint23/gofiberpg/src/http/request"
+	"github.com/jackc/pgx/v5"
+)
+
+type ImportJobRepo interface {
+	Insert(ctx context.Context, job *domain.ImportJob) (*domain.ImportJob, error)
+	Update(ctx context.Context, job *domain.ImportJob) error
+	FindById(ctx context.Context, jobId int) (*domain.ImportJob, error)
+	FindAll(ctx context.Context, params *request.ImportJobQueryParams) ([]*domain.ImportJob, error)
+	// MarkInterrupted fails the queued and running jobs whose owner has not
+	// updated them for staleAfter.
+	MarkInterrupted(ctx context.Context, staleAfter time.Duration) (int64, error)
+	InsertErrors(ctx context.Context, jobId int, rowErrors []*domain.ImportRowError) error
+	FindErrors(ctx context.Context, jobId int) ([]*domain.ImportRowError, error)
+}
+
+type ImportJobRepoImpl struct {
+	TxManager database.TxManager
+}
+
+func NewImportJobRepo(txManager database.TxManager) ImportJobRepo {
+	return &ImportJobRepoImpl{
+		TxManager: txManager,
+	}
+}
+
+const importJobColumns = "id,status,mode,file_name,rows_processed,rows_inserted,rows_updated,rows_skipped,rows_failed,coalesce(error,''),coalesce(owner,''),created_at,updated_at,started_at,finished_at"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanImportJob(row rowScanner) (*domain.ImportJob, error) {
+	job := &domain.ImportJob{}
+	err := row.Scan(&job.Id, &job.Status, &job.Mode, &job.FileName, &job.RowsProcessed, &job.RowsInserted, &job.RowsUpdated, &job.RowsSkipped, &job.RowsFailed, &job.Error, &job.Owner, &job.CreatedAt, &job.UpdatedAt, &job.StartedAt, &job.FinishedAt)
+	return job, err
+}
+
+// Insert implements ImportJobRepo.
+func (i *ImportJobRepoImpl) Insert(ctx context.Context, job *domain.ImportJob) (*domain.ImportJob, error) {
+	SQL := "insert into import_job(status, mode, file_name, owner) values($1, $2, $3, $4) returning " + importJobColumns
+	result, err := scanImportJob(i.TxManager.Querier(ctx).QueryRow(ctx, SQL, job.Status, job.Mode, job.FileName, job.Owner))
+	if err != nil {
+		return nil, dbError(err, "import job")
+	}
+
+	return result, nil
+}
+
+// Update implements ImportJobRepo. It also refreshes updated_at, the
+// heartbeat of the owner. A job that MarkInterrupted already failed is left
+// as it is.
+func (i *ImportJobRepoImpl) Update(ctx context.Context, job *domain.ImportJob) error {
+	SQL := `update import_job set status = $1, rows_processed = $2, rows_inserted = $3, rows_updated = $4,
+		rows_skipped = $5, rows_failed = $6, error = nullif($7, ''), started_at = $8, finished_at = $9, updated_at = now()
+		where id = $10 and status in ($11, $12)`
+	_, err := i.TxManager.Querier(ctx).Exec(ctx, SQL, job.Status, job.RowsProcessed, job.RowsInserted, job.RowsUpdated, job.RowsSkipped,
+		job.RowsFailed, job.Error, job.StartedAt, job.FinishedAt, job.Id, domain.ImportJobQueued, domain.ImportJobRunning)
+	return dbError(err, "import job")
+}
+
+// FindById implements ImportJobRepo.
+func (i *ImportJobRepoImpl) FindById(ctx context.Context, jobId int) (*domain.ImportJob, error) {
+	SQL := "select " + importJobColumns + " from import_job where id = $1"
+	job, err := scanImportJob(i.TxManager.Querier(ctx).QueryRow(ctx, SQL, jobId))
+	if err != nil {
+		return nil, dbError(err, "import job")
+	}
+
+	return job, nil
+}
+
+// FindAll implements ImportJobRepo.
+func (i *ImportJobRepoImpl) FindAll(ctx context.Context, params *request.ImportJobQueryParams) ([]*domain.ImportJob, error) {
+	SQL := "select " + importJobColumns + " from import_job order by id desc limit $1"
+	rows, err := i.TxManager.Querier(ctx).Query(ctx, SQL, params.Limit)
+	if err != nil {
+		return nil, dbError(err, "import job")
+	}
+	defer rows.Close()
+
+	var jobs []*domain.ImportJob
+	for rows.Next() {
+		job, errScan := scanImportJob(rows)
+		if errScan != nil {
+			return nil, dbError(errScan, "import job")
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, dbError(rows.Err(), "import job")
+}
+
+// MarkInterrupted implements ImportJobRepo.
+func (i *ImportJobRepoImpl) MarkInterrupted(ctx context.Context, staleAfter time.Duration) (int64, error) {
+	SQL := `update import_job set status = $1, error = 'interrupted, owner ' || coalesce(owner, 'unknown') || ' stopped responding',
+			finished_at = now(), updated_at = now()
+		where status in ($2, $3) and updated_at < now() - make_interval(secs => $4)`
+	tag, err := i.TxManager.Querier(ctx).Exec(ctx, SQL, domain.ImportJobFailed, domain.ImportJobQueued, domain.ImportJobRunning, staleAfter.Seconds())
+	if err != nil {
+		return 0, dbError(err, "import job")
+	}
+	return tag.RowsAffected(), nil
+}
+
+// InsertErrors implements ImportJobRepo.
+func (i *ImportJobRepoImpl) InsertErrors(ctx context.Context, jobId int, rowErrors []*domain.ImportRowError) error {
+	if len(rowErrors) == 0 {
+		return nil
+	}
+
+	rows := pgx.CopyFromSlice(len(rowErrors), func(idx int) ([]any, error) {
+		rowError := rowErrors[idx]
+		return []any{jobId, rowError.Line, rowError.Column, rowError.Message}, nil
+	})
+	_, err := i.TxManager.Querier(ctx).CopyFrom(ctx, pgx.Identifier{"import_job_error"}, []string{"job_id", "line", "column_name", "message"}, rows)
+	return dbError(err, "import job error")
+}
+
+// FindErrors implements ImportJobRepo.
+func (i *ImportJobRepoImpl) FindErrors(ctx context.Context, jobId int) ([]*domain.ImportRowError, error) {
+	SQL := "select line,coalesce(column_name,''),message from import_job_error where job_id = $1 order by line asc, id asc"
+	rows, err := i.TxManager.Querier(ctx).Query(ctx, SQL, jobId)
+	if err != nil {
+		return nil, dbError(err, "import job error")
+	}
+	defer rows.Close()
+
+	var rowErrors []*domain.ImportRowError
+	for rows.Next() {
+		rowError := &domain.ImportRowError{}
+		errScan := rows.Scan(&rowError.Line, &rowError.Column, &rowError.Message)
+		if errScan != nil {
+			return nil, dbError(errScan, "import job error")
+		}
+		rowErrors = append(rowErrors, rowError)
+	}
+	return rowErrors, dbError(rows.Err(), "import job error")
+}
@@ -0,0 +1,182 @@
+package repo
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daint23/gofiberpg/src/domain"
+)
+
+// sortColumn is a field that a listing may be ordered by. Expr is the SQL
+// expression and Cast the type cursor values are converted to.
+type sortColumn struct {
+	Expr string
+	Cast string
+}
+
+// filterColumn is a field that a listing may be filtered by, with the
+// operators allowed on it.
+type filterColumn struct {
+	Expr      string
+	Cast      string
+	Operators []string
+}
+
+var (
+	numberOperators = []string{domain.FilterEq, domain.FilterNe, domain.FilterLt, domain.FilterLte, domain.FilterGt, domain.FilterGte, domain.FilterIn}
+	textOperators   = []string{domain.FilterEq, domain.FilterNe, domain.FilterLike, domain.FilterIlike, domain.FilterIn}
+	timeOperators   = []string{domain.FilterEq, domain.FilterNe, domain.FilterLt, domain.FilterLte, domain.FilterGt, domain.FilterGte}
+)
+
+// timeLayouts are the accepted forms of a timestamp filter value.
+var timeLayouts = []string{time.RFC3339Nano, time.DateOnly}
+
+var comparisons = map[string]string{
+	domain.FilterEq:    "=",
+	domain.FilterNe:    "<>",
+	domain.FilterLt:    "<",
+	domain.FilterLte:   "<=",
+	domain.FilterGt:    ">",
+	domain.FilterGte:   ">=",
+	domain.FilterLike:  "like",
+	domain.FilterIlike: "ilike",
+}
+
+// checkListQuery rejects filters and sort keys outside the whitelist, and
+// values that do not fit the column type. Every problem is reported at once.
+func checkListQuery(filters []domain.Filter, sort []domain.SortKey, filterColumns map[string]filterColumn, sortColumns map[string]sortColumn) error {
+	var errFields []*domain.FieldError
+	for _, filter := range filters {
+		column, ok := filterColumns[filter.Field]
+		if !ok {
+			errFields = append(errFields, &domain.FieldError{Field: filter.Field, Tag: "unknown_field"})
+			continue
+		}
+		if !slices.Contains(column.Operators, filter.Operator) {
+			errFields = append(errFields, &domain.FieldError{Field: filter.Field, Tag: "unknown_operator", Param: filter.Operator})
+			continue
+		}
+
+		for _, value := range filter.Values {
+			if filter.Operator == domain.FilterIsNull {
+				_, errBool := strconv.ParseBool(value)
+				if errBool != nil {
+					errFields = append(errFields, &domain.FieldError{Field: filter.Field, Tag: "boolean", Param: value})
+				}
+				continue
+			}
+			switch column.Cast {
+			case "integer":
+				_, errInt := strconv.ParseInt(value, 10, 32)
+				if errInt != nil {
+					errFields = append(errFields, &domain.FieldError{Field: filter.Field, Tag: "integer", Param: value})
+				}
+			case "timestamptz":
+				if !isTimestamp(value) {
+					errFields = append(errFields, &domain.FieldError{Field: filter.Field, Tag: "datetime", Param: value})
+				}
+			}
+		}
+	}
+
+	for _, key := range sort {
+		if _, ok := sortColumns[key.Field]; !ok {
+			errFields = append(errFields, &domain.FieldError{Field: "sort", Tag: "unknown_field", Param: key.Field})
+		}
+	}
+
+	if len(errFields) > 0 {
+		return domain.NewValidationError(errFields)
+	}
+	return nil
+}
+
+func isTimestamp(value string) bool {
+	for _, layout := range timeLayouts {
+		_, err := time.Parse(layout, value)
+		if err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// queryBuilder collects where conditions and their positional arguments.
+type queryBuilder struct {
+	conditions []string
+	args       []any
+}
+
+func (b *queryBuilder) arg(value any) string {
+	b.args = append(b.args, value)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+func (b *queryBuilder) where() string {
+	if len(b.conditions) == 0 {
+		return ""
+	}
+	return " where " + strings.Join(b.conditions, " and ")
+}
+
+// filter adds one condition per filter. Fields and operators must have been
+// checked with checkListQuery; only whitelisted expressions end up in the
+// SQL, values are always passed as arguments.
+func (b *queryBuilder) filter(filters []domain.Filter, columns map[string]filterColumn) {
+	for _, filter := range filters {
+		column := columns[filter.Field]
+		switch filter.Operator {
+		case domain.FilterIsNull:
+			isNull, _ := strconv.ParseBool(filter.Values[0])
+			if isNull {
+				b.conditions = append(b.conditions, column.Expr+" is null")
+			} else {
+				b.conditions = append(b.conditions, column.Expr+" is not null")
+			}
+		case domain.FilterIn:
+			b.conditions = append(b.conditions, fmt.Sprintf("%s = any(%s::%s[])", column.Expr, b.arg(filter.Values), column.Cast))
+		default:
+			b.conditions = append(b.conditions, fmt.Sprintf("%s %s %s::%s", column.Expr, comparisons[filter.Operator], b.arg(filter.Values[0]), column.Cast))
+		}
+	}
+}
+
+// orderBy returns the order by clause for keys, reversed when the page is
+// read backwards.
+func orderBy(keys []domain.SortKey, columns map[string]sortColumn, backward bool) string {
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		direction := "asc"
+		if key.Desc != backward {
+			direction = "desc"
+		}
+		parts = append(parts, columns[key.Field].Expr+" "+direction)
+	}
+	return " order by " + strings.Join(parts, ", ")
+}
+
+// keyset adds the condition that continues a listing after the row whose
+// sort values are after. Keys may mix directions, so the row comparison is
+// spelled out as (a > x) or (a = x and b > y) ...
+func (b *queryBuilder) keyset(keys []domain.SortKey, columns map[string]sortColumn, after []string, backward bool) {
+	var alternatives []string
+	for idx, key := range keys {
+		column := columns[key.Field]
+		var parts []string
+		for prev := 0; prev < idx; prev++ {
+			prevColumn := columns[keys[prev].Field]
+			parts = append(parts, fmt.Sprintf("%s = %s::%s", prevColumn.Expr, b.arg(after[prev]), prevColumn.Cast))
+		}
+
+		operator := ">"
+		if key.Desc != backward {
+			operator = "<"
+		}
+		parts = append(parts, fmt.Sprintf("%s %s %s::%s", column.Expr, operator, b.arg(after[idx]), column.Cast))
+		alternatives = append(alternatives, "("+strings.Join(parts, " and ")+")")
+	}
+	b.conditions = append(b.conditions, "("+strings.Join(alternatives, " or ")+")")
+}
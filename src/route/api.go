@@ -1,9 +1,11 @@
 package route
 
 import (
-	"sync"
+	"context"
 
+	"github.com/daint23/gofiberpg/src/config"
 	"github.com/daint23/gofiberpg/src/controller"
+	"github.com/daint23/gofiberpg/src/database"
 	"github.com/daint23/gofiberpg/src/repo"
 	"github.com/daint23/gofiberpg/src/service"
 	"github.com/go-playground/validator/v10"
@@ -11,19 +13,49 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func ApiRoute(app *fiber.App, db *pgxpool.Pool, validate *validator.Validate, wg *sync.WaitGroup) {
-	categoryRepository := repo.NewCategoryRepo(db)
-	categoryService := service.NewCategoryService(categoryRepository, validate, wg)
-	categoryController := controller.NewCategoryController(categoryService)
+// ApiRoute registers the API routes. The background jobs it starts stop
+// when ctx is done; the returned wait blocks until the import jobs have.
+func ApiRoute(ctx context.Context, app *fiber.App, db *pgxpool.Pool, validate *validator.Validate, cfg *config.Config) (wait func()) {
+	txManager := database.NewTxManager(db)
+
+	categoryService := NewCategoryService(txManager, db, validate, cfg)
+	categoryController := controller.NewCategoryController(categoryService, cfg.HTTP.RequireIfMatch)
+	go categoryService.PurgeEvery(ctx, cfg.Trash.PurgeInterval)
+
+	importJobRepository := repo.NewImportJobRepo(txManager)
+	importFailureRepository := repo.NewImportFailureRepo(txManager)
+	importJobService := service.NewImportJobService(ctx, importJobRepository, importFailureRepository, categoryService, txManager, validate, config.NewInstanceId(), cfg.Import.JobStaleAfter)
+	importJobController := controller.NewImportJobController(importJobService)
+	go importJobService.MarkInterruptedEvery(ctx, cfg.Import.JobStaleAfter)
 
 	api := app.Group("/api/v1")
 
 	api.Post("/categories", categoryController.Insert)
 	api.Get("/categories", categoryController.FindAll)
 	api.Get("/categories/import", categoryController.ImportCsv)
+	api.Get("/categories/search", categoryController.Search)
+	api.Get("/categories/trash", categoryController.Trash)
+	api.Delete("/categories/trash", categoryController.Purge)
 	api.Get("/categories/:id", categoryController.FindById)
+	api.Get("/categories/:id/subtree", categoryController.Subtree)
+	api.Get("/categories/:id/ancestors", categoryController.Ancestors)
+	api.Post("/categories/:id/move", categoryController.Move)
+	api.Get("/categories/:id/history", categoryController.History)
+	api.Get("/categories/:id/history/:rev", categoryController.Revision)
+	api.Post("/categories/:id/history/:rev/revert", categoryController.Revert)
 	api.Put("/categories/:id", categoryController.Update)
+	api.Patch("/categories/:id", categoryController.Patch)
 	api.Delete("/categories/:id", categoryController.Delete)
+	api.Post("/categories/:id/restore", categoryController.Restore)
 	api.Post("/categories/export", categoryController.ExportCsv)
-	api.Post("/categories/exportgo", categoryController.ExportCsvGo)
+	api.Post("/categories/exportgo", importJobController.Insert)
+
+	api.Post("/imports", importJobController.Insert)
+	api.Get("/imports", importJobController.FindAll)
+	api.Get("/imports/failures", importJobController.FindFailures)
+	api.Post("/imports/failures/:id/replay", importJobController.ReplayFailure)
+	api.Get("/imports/:id", importJobController.FindById)
+	api.Get("/imports/:id/errors", importJobController.FindErrors)
+
+	return importJobService.Wait
 }
@@ -0,0 +1,17 @@
+package route
+
+import (
+	"github.com/daint23/gofiberpg/src/config"
+	"github.com/daint23/gofiberpg/src/database"
+	"github.com/daint23/gofiberpg/src/repo"
+	"github.com/daint23/gofiberpg/src/service"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NewCategoryService wires the category service the same way for the HTTP
+// server and the command line.
+func NewCategoryService(txManager database.TxManager, db *pgxpool.Pool, validate *validator.Validate, cfg *config.Config) service.CategoryService {
+	categoryRepository := repo.NewCategoryRepo(txManager)
+	return service.NewCategoryService(categoryRepository, txManager, validate, config.NewRetryPolicy(cfg), config.NewWorkerPool(cfg, db), cfg.Trash.Retention)
+}
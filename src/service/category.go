@@ -1,266 +1,987 @@
 package service
 
 import (
+	"bytes"
 	"context"
-	"encoding/csv"
+	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"log"
 	"mime/multipart"
-	"os"
-	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/daint23/gofiberpg/src/codec"
+	"github.com/daint23/gofiberpg/src/database"
 	"github.com/daint23/gofiberpg/src/domain"
 	"github.com/daint23/gofiberpg/src/helper"
 	"github.com/daint23/gofiberpg/src/http/request"
 	"github.com/daint23/gofiberpg/src/http/response"
+	"github.com/daint23/gofiberpg/src/patch"
 	"github.com/daint23/gofiberpg/src/repo"
+	"github.com/daint23/gofiberpg/src/worker"
 	"github.com/go-playground/validator/v10"
 )
 
 type CategoryService interface {
-	Insert(ctx context.Context, req *request.CategoryCreateRequest) *response.CategoryResponse
-	Update(ctx context.Context, req *request.CategoryUpdateRequest) *response.CategoryResponse
-	Delete(ctx context.Context, categoryId int) error
-	FindById(ctx context.Context, categoryId int) *response.CategoryResponse
-	FindAll(ctx context.Context, params *request.CategoryQueryParams) []*response.CategoryResponse
-	ExportCsv(ctx context.Context, head *multipart.FileHeader) error
-	ImportCsv(ctx context.Context) error
-	DispatchWorkers(jobs <-chan *domain.Category)
-	OpenCsvFile(head *multipart.FileHeader) (*csv.Reader, error)
-	ReadCsvFilePerLineThenSendToWorker(csvReader *csv.Reader, jobs chan<- *domain.Category)
+	Insert(ctx context.Context, req *request.CategoryCreateRequest) (*response.CategoryResponse, error)
+	Update(ctx context.Context, req *request.CategoryUpdateRequest) (*response.CategoryResponse, error)
+	Patch(ctx context.Context, req *request.CategoryPatchRequest) (*response.CategoryResponse, error)
+	Delete(ctx context.Context, categoryId int, ifMatch string) error
+	Restore(ctx context.Context, categoryId int) (*response.CategoryResponse, error)
+	Trash(ctx context.Context, params *request.CategoryQueryParams) (*response.CategoryPageResponse, error)
+	Purge(ctx context.Context) (*response.PurgeResponse, error)
+	PurgeEvery(ctx context.Context, interval time.Duration)
+	FindById(ctx context.Context, categoryId int) (*response.CategoryResponse, error)
+	FindAll(ctx context.Context, params *request.CategoryQueryParams) (*response.CategoryPageResponse, error)
+	Subtree(ctx context.Context, categoryId int, params *request.CategoryTreeParams) (*response.CategoryResponse, error)
+	Ancestors(ctx context.Context, categoryId int) ([]*response.CategoryResponse, error)
+	Move(ctx context.Context, req *request.CategoryMoveRequest) (*response.CategoryResponse, error)
+	History(ctx context.Context, categoryId int, params *request.CategoryHistoryParams) (*response.CategoryRevisionPageResponse, error)
+	Revision(ctx context.Context, categoryId int, rev int) (*response.CategoryRevisionResponse, error)
+	Revert(ctx context.Context, req *request.CategoryRevertRequest) (*response.CategoryResponse, error)
+	Search(ctx context.Context, params *request.CategorySearchParams) ([]*response.CategorySearchResponse, error)
+	ExportCsv(ctx context.Context, head *multipart.FileHeader, req *request.ImportRequest) (*domain.ImportResult, error)
+	// ImportFile imports a file that was not uploaded, such as the one given
+	// to the import command, like ExportCsv does an upload. The format comes
+	// from req or fileName.
+	ImportFile(ctx context.Context, r io.Reader, fileName string, req *request.ImportRequest) (*domain.ImportResult, error)
+	ImportCsv(ctx context.Context, w io.Writer, export *CategoryExport) error
+	NewExport(req *request.ExportRequest, accept string) (*CategoryExport, error)
+	ImportRows(ctx context.Context, decoder codec.Decoder, options *domain.ImportOptions, progress *ImportProgress) error
+	// ImportRow writes one row with the semantics of an import mode and
+	// returns the category the row ended up in.
+	ImportRow(ctx context.Context, row *domain.ImportRow, mode string) (*response.CategoryResponse, error)
 }
 
 type CategoryServiceImpl struct {
 	CategoryRepo repo.CategoryRepo
+	TxManager    database.TxManager
 	Validator    *validator.Validate
-	Wg           *sync.WaitGroup
+	Retry        *helper.RetryPolicy
+	Pool         *worker.Pool
+	// TrashRetention is how long a deleted category stays in the trash
+	// before Purge removes it.
+	TrashRetention time.Duration
 }
 
-func NewCategoryService(categoryRepo repo.CategoryRepo, validator *validator.Validate, wg *sync.WaitGroup) CategoryService {
+func NewCategoryService(categoryRepo repo.CategoryRepo, txManager database.TxManager, validator *validator.Validate, retry *helper.RetryPolicy, pool *worker.Pool, trashRetention time.Duration) CategoryService {
 	return &CategoryServiceImpl{
-		CategoryRepo: categoryRepo,
-		Validator:    validator,
-		Wg:           wg,
+		CategoryRepo:   categoryRepo,
+		TxManager:      txManager,
+		Validator:      validator,
+		Retry:          retry,
+		Pool:           pool,
+		TrashRetention: trashRetention,
 	}
 }
 
-// ImportCsvCsv implements CategoryService.
-func (c *CategoryServiceImpl) ImportCsv(ctx context.Context) error {
-	categories := c.CategoryRepo.ImportCsv(ctx)
+// CategoryExport is a checked download request. It is built before the
+// response starts streaming, so a bad option can still be answered with 400.
+type CategoryExport struct {
+	Format  *codec.Format
+	Options *codec.Options
+	Filters []domain.Filter
+	Sort    []domain.SortKey
+}
 
-	pathStorage := "./src/storage"
-	errMkd := os.MkdirAll(pathStorage, 0755)
-	if errMkd != nil {
-		panic(helper.NewHTTPError(404, errMkd))
+// ImportCsv implements CategoryService.
+func (c *CategoryServiceImpl) ImportCsv(ctx context.Context, w io.Writer, export *CategoryExport) error {
+	writer, errEnc := export.Format.NewEncoder(w, export.Options)
+	if errEnc != nil {
+		return errEnc
 	}
 
-	fileName := "output.csv"
-	file, errCr := os.Create(filepath.Join(pathStorage, fileName))
-	if errCr != nil {
-		return errCr
+	errWr := writer.Write(categoryCsvColumns)
+	if errWr != nil {
+		return errWr
 	}
-	defer file.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	errRows := c.CategoryRepo.ImportCsv(ctx, export.Filters, export.Sort, func(row *domain.ImportRow) error {
+		return writer.Write([]string{row.Category.Name, row.Category.Description, row.Parent})
+	})
+	if errRows != nil {
+		return errRows
+	}
 
-	errWr := writer.Write([]string{"name", "description"})
-	if errWr != nil {
-		return errWr
+	return writer.Flush()
+}
+
+// NewExport implements CategoryService.
+func (c *CategoryServiceImpl) NewExport(req *request.ExportRequest, accept string) (*CategoryExport, error) {
+	format, options, errFormat := newExportFormat(req, accept)
+	if errFormat != nil {
+		return nil, errFormat
 	}
 
-	for _, category := range categories {
-		errWri := writer.Write([]string{category.Name, category.Description})
-		if errWri != nil {
-			panic(helper.NewHTTPError(500, errors.New("woke")))
-		}
+	sortKeys, errSort := parseSort(req.Sort, categoryDefaultSort)
+	if errSort != nil {
+		return nil, errSort
+	}
+	filters, errFilter := parseFilters(req.Filters, categoryExportOptions...)
+	if errFilter != nil {
+		return nil, errFilter
+	}
+	errCheck := c.CategoryRepo.ValidateQuery(filters, sortKeys)
+	if errCheck != nil {
+		return nil, errCheck
 	}
 
-	return nil
+	return &CategoryExport{Format: format, Options: options, Filters: filters, Sort: sortKeys}, nil
 }
 
-func (c *CategoryServiceImpl) ExportCsv(ctx context.Context, head *multipart.FileHeader) error {
+// ExportCsv implements CategoryService.
+func (c *CategoryServiceImpl) ExportCsv(ctx context.Context, head *multipart.FileHeader, req *request.ImportRequest) (*domain.ImportResult, error) {
+	options, errOpt := newImportOptions(req, c.Validator)
+	if errOpt != nil {
+		return nil, errOpt
+	}
+
 	file, errOpen := head.Open()
 	if errOpen != nil {
-		panic(helper.NewHTTPError(500, errOpen))
+		return nil, errOpen
 	}
+	defer file.Close()
 
-	records, errRead := csv.NewReader(file).ReadAll()
-	if errRead != nil {
-		panic(helper.NewHTTPError(500, errRead))
-	}
+	return c.importFrom(ctx, file, head.Header.Get("Content-Type"), head.Filename, options)
+}
 
-	// mengabaikan row pertama csv
-	if len(records) > 0 {
-		records = records[1:]
+// ImportFile implements CategoryService.
+func (c *CategoryServiceImpl) ImportFile(ctx context.Context, r io.Reader, fileName string, req *request.ImportRequest) (*domain.ImportResult, error) {
+	options, errOpt := newImportOptions(req, c.Validator)
+	if errOpt != nil {
+		return nil, errOpt
 	}
+	return c.importFrom(ctx, r, "", fileName, options)
+}
 
-	var valueStrings []string
-	var valueArgs []interface{}
-
-	totalCol := 2
-	i := 0
+func (c *CategoryServiceImpl) importFrom(ctx context.Context, r io.Reader, contentType string, fileName string, options *domain.ImportOptions) (*domain.ImportResult, error) {
+	decoder, errDec := openImportDecoder(r, contentType, fileName, options)
+	if errDec != nil {
+		return nil, errDec
+	}
 
-	for _, record := range records {
-		valueStrings = append(valueStrings, fmt.Sprintf("($%d,$%d)", i*totalCol+1, i*totalCol+2))
-		valueArgs = append(valueArgs, record[0], record[1])
-		i++
+	// header dicek dulu sebelum ada row yang ditulis
+	columns, errHead := readCategoryColumns(decoder, options.Mapping)
+	if errHead == io.EOF {
+		return &domain.ImportResult{DryRun: options.DryRun}, nil
+	}
+	if errHead != nil {
+		return nil, errHead
 	}
 
-	errIn := c.CategoryRepo.ExportCsv(ctx, valueStrings, valueArgs)
+	source := newCategoryRowSource(decoder, c.Validator, columns)
+	result, errIn := c.CategoryRepo.ExportCsv(ctx, source, options)
 	if errIn != nil {
-		panic(helper.NewHTTPError(500, errIn))
+		return nil, errIn
 	}
 
-	return nil
+	result.Failed += source.errors.rows
+	result.Errors = append(source.errors.errors, result.Errors...)
+	sort.SliceStable(result.Errors, func(i, j int) bool {
+		return result.Errors[i].Line < result.Errors[j].Line
+	})
+	result.Errors = firstRowErrors(result.Errors, maxImportErrors)
+	return result, nil
 }
 
 // Delete implements CategoryService.
-func (c *CategoryServiceImpl) Delete(ctx context.Context, categoryId int) error {
-	findCategory := c.CategoryRepo.FindById(ctx, categoryId)
+func (c *CategoryServiceImpl) Delete(ctx context.Context, categoryId int, ifMatch string) error {
+	return c.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		findCategory, errFind := c.CategoryRepo.FindByIdForUpdate(ctx, categoryId)
+		if errFind != nil {
+			return errFind
+		}
+		errMatch := checkIfMatch(findCategory, ifMatch)
+		if errMatch != nil {
+			return errMatch
+		}
+
+		return c.CategoryRepo.Delete(ctx, findCategory.Id)
+	})
+}
+
+// Restore implements CategoryService. The parent, if any, must not be in
+// the trash itself; it is locked so it cannot be trashed meanwhile.
+func (c *CategoryServiceImpl) Restore(ctx context.Context, categoryId int) (*response.CategoryResponse, error) {
+	var result *domain.Category
+	err := c.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		findCategory, errFind := c.CategoryRepo.FindTrashedByIdForUpdate(ctx, categoryId)
+		if errFind != nil {
+			return errFind
+		}
 
-	err := c.CategoryRepo.Delete(ctx, findCategory.Id)
+		if findCategory.ParentId != nil {
+			_, errParent := c.CategoryRepo.FindByIdForUpdate(ctx, *findCategory.ParentId)
+			if errors.Is(errParent, domain.ErrNotFound) {
+				return domain.NewError(domain.ErrConflict, "parent category is in the trash, restore it first", nil)
+			}
+			if errParent != nil {
+				return errParent
+			}
+		}
+
+		var errRestore error
+		result, errRestore = c.CategoryRepo.Restore(ctx, findCategory.Id)
+		return errRestore
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return toCategoryResponse(result), nil
+}
+
+// Trash implements CategoryService.
+func (c *CategoryServiceImpl) Trash(ctx context.Context, params *request.CategoryQueryParams) (*response.CategoryPageResponse, error) {
+	if params.Nested {
+		return nil, domain.NewValidationError([]*domain.FieldError{{Field: "nested", Tag: "unsupported"}})
+	}
+	return c.findPage(ctx, params, true)
+}
+
+// Purge implements CategoryService.
+func (c *CategoryServiceImpl) Purge(ctx context.Context) (*response.PurgeResponse, error) {
+	before := time.Now().Add(-c.TrashRetention)
+	purged, err := c.CategoryRepo.Purge(ctx, before)
+	if err != nil {
+		return nil, err
+	}
+	return &response.PurgeResponse{Purged: purged, Before: before}, nil
+}
+
+// PurgeEvery implements CategoryService. It purges the trash once per
+// interval until ctx is done; a zero interval disables it.
+func (c *CategoryServiceImpl) PurgeEvery(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			result, err := c.Purge(ctx)
+			if err != nil {
+				log.Println("=> purge trash:", err)
+				continue
+			}
+			if result.Purged > 0 {
+				log.Println("=> purged", result.Purged, "categories from trash")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// FindAll implements CategoryService.
+func (c *CategoryServiceImpl) FindAll(ctx context.Context, params *request.CategoryQueryParams) (*response.CategoryPageResponse, error) {
+	return c.findPage(ctx, params, false)
+}
+
+func (c *CategoryServiceImpl) findPage(ctx context.Context, params *request.CategoryQueryParams, trashed bool) (*response.CategoryPageResponse, error) {
+	errVal := helper.ValidateStruct(params, c.Validator)
+	if errVal != nil {
+		return nil, errVal
+	}
+
+	sortKeys, errSort := parseSort(params.Sort, categoryDefaultSort)
+	if errSort != nil {
+		return nil, errSort
+	}
+	filters, errFilter := parseFilters(params.Filters, categoryListOptions...)
+	if errFilter != nil {
+		return nil, errFilter
+	}
+
+	if params.Nested {
+		filters = append(filters, domain.Filter{Field: "parentId", Operator: domain.FilterIsNull, Values: []string{"true"}})
+	}
+
+	query, errQuery := newPageQuery(params.Cursor, params.Limit, params.IncludeTotal, sortKeys)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+	query.Filters = filters
+	query.Trashed = trashed
+
+	page, err := c.CategoryRepo.FindAll(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	categoryResponses := []*response.CategoryResponse{}
+	if params.Nested && len(page.Categories) > 0 {
+		rootIds := make([]int, 0, len(page.Categories))
+		for _, category := range page.Categories {
+			rootIds = append(rootIds, category.Id)
+		}
+		subtrees, errTree := c.CategoryRepo.FindSubtrees(ctx, rootIds, 0)
+		if errTree != nil {
+			return nil, errTree
+		}
+		nodes := buildCategoryTree(subtrees)
+		for _, category := range page.Categories {
+			node, ok := nodes[category.Id]
+			if !ok {
+				node = toCategoryResponse(category)
+			}
+			categoryResponses = append(categoryResponses, node)
+		}
+	} else {
+		for _, category := range page.Categories {
+			categoryResponses = append(categoryResponses, toCategoryResponse(category))
+		}
+	}
+
+	next, prev := pageCursors(query, page.Categories, page.HasMore, func(category *domain.Category) []string {
+		return categorySortValues(category, query.Sort)
+	})
+	return &response.CategoryPageResponse{
+		Data: categoryResponses,
+		Page: &response.PageResponse{
+			Limit:      query.Limit,
+			NextCursor: next,
+			PrevCursor: prev,
+			Total:      page.Total,
+		},
+	}, nil
 }
 
-func (c *CategoryServiceImpl) FindAll(ctx context.Context, params *request.CategoryQueryParams) []*response.CategoryResponse {
-	categories := c.CategoryRepo.FindAll(ctx, params)
+// Search implements CategoryService.
+func (c *CategoryServiceImpl) Search(ctx context.Context, params *request.CategorySearchParams) ([]*response.CategorySearchResponse, error) {
+	params.Q = strings.TrimSpace(params.Q)
+	errVal := helper.ValidateStruct(params, c.Validator)
+	if errVal != nil {
+		return nil, errVal
+	}
+
+	limit := params.Limit
+	if limit == 0 {
+		limit = domain.PageDefaultLimit
+	}
+	if limit > domain.PageMaxLimit {
+		limit = domain.PageMaxLimit
+	}
+
+	hits, err := c.CategoryRepo.Search(ctx, params.Q, limit)
+	if err != nil {
+		return nil, err
+	}
+	searchResponses := []*response.CategorySearchResponse{}
+	for _, hit := range hits {
+		searchResponses = append(searchResponses, &response.CategorySearchResponse{
+			Id:          hit.Category.Id,
+			Name:        hit.Category.Name,
+			Description: hit.Category.Description,
+			ParentId:    hit.Category.ParentId,
+			Score:       hit.Score,
+			Snippet:     hit.Snippet,
+		})
+	}
+	return searchResponses, nil
+}
+
+var categoryDefaultSort = []domain.SortKey{{Field: "id"}}
+
+// query options that are not filters
+var (
+	categoryListOptions   = []string{"cursor", "limit", "includeTotal", "sort", "nested"}
+	categoryExportOptions = []string{"format", "delimiter", "quote", "bom", "sort"}
+)
+
+// categorySortValues returns the values of category for each sort key, in
+// the form the repo compares them.
+func categorySortValues(category *domain.Category, keys []domain.SortKey) []string {
+	values := make([]string, 0, len(keys))
+	for _, key := range keys {
+		switch key.Field {
+		case "id":
+			values = append(values, strconv.Itoa(category.Id))
+		case "name":
+			values = append(values, category.Name)
+		case "description":
+			values = append(values, category.Description)
+		case "createdAt":
+			values = append(values, category.CreatedAt.Format(time.RFC3339Nano))
+		case "updatedAt":
+			values = append(values, category.UpdatedAt.Format(time.RFC3339Nano))
+		case "createdBy":
+			values = append(values, category.CreatedBy)
+		case "updatedBy":
+			values = append(values, category.UpdatedBy)
+		}
+	}
+	return values
+}
+
+func toCategoryResponse(category *domain.Category) *response.CategoryResponse {
+	return &response.CategoryResponse{
+		Id:          category.Id,
+		Name:        category.Name,
+		Description: category.Description,
+		ParentId:    category.ParentId,
+		DeletedAt:   category.DeletedAt,
+		Version:     category.Version,
+		CreatedAt:   category.CreatedAt,
+		UpdatedAt:   category.UpdatedAt,
+		CreatedBy:   category.CreatedBy,
+		UpdatedBy:   category.UpdatedBy,
+	}
+}
+
+// checkIfMatch rejects a write when ifMatch is set and does not list the
+// current version of category. The row must be locked so the version cannot
+// change before the write.
+func checkIfMatch(category *domain.Category, ifMatch string) error {
+	if ifMatch == "" || helper.MatchETag(ifMatch, helper.ETag(category.Version), false) {
+		return nil
+	}
+	return domain.NewError(domain.ErrPreconditionFailed, "category was changed by someone else, reload it and try again", nil)
+}
+
+// buildCategoryTree links categories read by FindSubtrees to their parents
+// and returns every node by id. Parents come before their children, so one
+// pass is enough.
+func buildCategoryTree(categories []*domain.Category) map[int]*response.CategoryResponse {
+	nodes := make(map[int]*response.CategoryResponse, len(categories))
+	for _, category := range categories {
+		node := toCategoryResponse(category)
+		nodes[category.Id] = node
+		if category.ParentId == nil {
+			continue
+		}
+		if parent, ok := nodes[*category.ParentId]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+	return nodes
+}
+
+// Subtree implements CategoryService.
+func (c *CategoryServiceImpl) Subtree(ctx context.Context, categoryId int, params *request.CategoryTreeParams) (*response.CategoryResponse, error) {
+	errVal := helper.ValidateStruct(params, c.Validator)
+	if errVal != nil {
+		return nil, errVal
+	}
+
+	categories, err := c.CategoryRepo.FindSubtrees(ctx, []int{categoryId}, params.Depth)
+	if err != nil {
+		return nil, err
+	}
+	if len(categories) == 0 {
+		return nil, domain.NewError(domain.ErrNotFound, "category not found", nil)
+	}
+	return buildCategoryTree(categories)[categoryId], nil
+}
+
+// Ancestors implements CategoryService.
+func (c *CategoryServiceImpl) Ancestors(ctx context.Context, categoryId int) ([]*response.CategoryResponse, error) {
+	_, errFind := c.CategoryRepo.FindById(ctx, categoryId)
+	if errFind != nil {
+		return nil, errFind
+	}
+
+	categories, err := c.CategoryRepo.FindAncestors(ctx, categoryId)
+	if err != nil {
+		return nil, err
+	}
 	categoryResponses := []*response.CategoryResponse{}
 	for _, category := range categories {
-		categoryResponses = append(categoryResponses, &response.CategoryResponse{Id: category.Id, Name: category.Name, Description: category.Description})
+		categoryResponses = append(categoryResponses, toCategoryResponse(category))
+	}
+	return categoryResponses, nil
+}
+
+// Move implements CategoryService.
+func (c *CategoryServiceImpl) Move(ctx context.Context, req *request.CategoryMoveRequest) (*response.CategoryResponse, error) {
+	errVal := helper.ValidateStruct(req, c.Validator)
+	if errVal != nil {
+		return nil, errVal
+	}
+
+	var result *domain.Category
+	err := c.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		findCategory, errFind := c.CategoryRepo.FindByIdForUpdate(ctx, req.Id)
+		if errFind != nil {
+			return errFind
+		}
+
+		var errMove error
+		result, errMove = c.CategoryRepo.Move(ctx, findCategory.Id, req.ParentId)
+		return errMove
+	})
+	if err != nil {
+		return nil, err
 	}
-	return categoryResponses
+	return toCategoryResponse(result), nil
 }
 
 // FindById implements CategoryService.
-func (c *CategoryServiceImpl) FindById(ctx context.Context, categoryId int) *response.CategoryResponse {
-	result := c.CategoryRepo.FindById(ctx, categoryId)
-	return &response.CategoryResponse{Id: result.Id, Name: result.Name, Description: result.Description}
+func (c *CategoryServiceImpl) FindById(ctx context.Context, categoryId int) (*response.CategoryResponse, error) {
+	result, err := c.CategoryRepo.FindById(ctx, categoryId)
+	if err != nil {
+		return nil, err
+	}
+	return toCategoryResponse(result), nil
 }
 
 // Insert implements CategoryService.
-func (c *CategoryServiceImpl) Insert(ctx context.Context, req *request.CategoryCreateRequest) *response.CategoryResponse {
+func (c *CategoryServiceImpl) Insert(ctx context.Context, req *request.CategoryCreateRequest) (*response.CategoryResponse, error) {
 	errVal := helper.ValidateStruct(req, c.Validator)
 	if errVal != nil {
-		panic(helper.NewHTTPInputValidationError(errVal))
+		return nil, errVal
 	}
 
 	category := &domain.Category{
 		Name:        req.Name,
 		Description: req.Description,
+		ParentId:    req.ParentId,
+	}
+	if path := normalizeCategoryPath(req.Parent); path != "" {
+		parent, errParent := c.CategoryRepo.FindByPath(ctx, path)
+		if errors.Is(errParent, domain.ErrNotFound) {
+			return nil, domain.NewError(domain.ErrValidation, "parent category not found", nil)
+		}
+		if errParent != nil {
+			return nil, errParent
+		}
+		category.ParentId = &parent.Id
 	}
 
-	result := c.CategoryRepo.Insert(ctx, category)
-	return &response.CategoryResponse{
-		Id:          result.Id,
-		Name:        result.Name,
-		Description: result.Description,
+	result, err := c.CategoryRepo.Insert(ctx, category)
+	if err != nil {
+		return nil, err
 	}
+	return toCategoryResponse(result), nil
 }
 
 // Update implements CategoryService.
-func (c *CategoryServiceImpl) Update(ctx context.Context, req *request.CategoryUpdateRequest) *response.CategoryResponse {
+func (c *CategoryServiceImpl) Update(ctx context.Context, req *request.CategoryUpdateRequest) (*response.CategoryResponse, error) {
 	errVal := helper.ValidateStruct(req, c.Validator)
 	if errVal != nil {
-		panic(helper.NewHTTPInputValidationError(errVal))
+		return nil, errVal
 	}
 
-	findCategory := c.CategoryRepo.FindById(ctx, req.Id)
+	var result *domain.Category
+	err := c.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		findCategory, errFind := c.CategoryRepo.FindByIdForUpdate(ctx, req.Id)
+		if errFind != nil {
+			return errFind
+		}
+		errMatch := checkIfMatch(findCategory, req.IfMatch)
+		if errMatch != nil {
+			return errMatch
+		}
 
-	category := &domain.Category{
-		Id:          findCategory.Id,
-		Name:        req.Name,
-		Description: req.Description,
-	}
+		category := &domain.Category{
+			Id:          findCategory.Id,
+			Name:        req.Name,
+			Description: req.Description,
+		}
 
-	result := c.CategoryRepo.Update(ctx, category)
-	return &response.CategoryResponse{
-		Id:          result.Id,
-		Name:        result.Name,
-		Description: result.Description,
+		var errUp error
+		result, errUp = c.CategoryRepo.Update(ctx, category)
+		return errUp
+	})
+	if err != nil {
+		return nil, err
 	}
+	return toCategoryResponse(result), nil
 }
 
-func (service *CategoryServiceImpl) DispatchWorkers(jobs <-chan *domain.Category) {
-	for workerIndex := 0; workerIndex <= 50; workerIndex++ {
-		go func(workerIndex int, jobs <-chan *domain.Category) {
-			counter := 0
-			for job := range jobs {
-				service.importData(workerIndex, counter, job)
-				service.Wg.Done()
-				counter++
-			}
-		}(workerIndex, jobs)
-	}
+// categoryPatchDocument is the document a patch is applied to. id and
+// version are read-only, a JSON Patch may still test them.
+type categoryPatchDocument struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	ParentId    *int   `json:"parentId"`
+	Version     int    `json:"version"`
 }
 
-func (service *CategoryServiceImpl) importData(workerIndex int, counter int, request *domain.Category) {
-	for {
-		var outerError error
-		func(outerError *error) {
-			defer func() {
-				if err := recover(); err != nil {
-					*outerError = fmt.Errorf("%v", err)
-				}
-			}()
-
-			ctx := context.Background()
-
-			errIn := service.CategoryRepo.ExportCsvGo(ctx, request)
-			if errIn != nil {
-				panic(helper.NewHTTPError(500, errors.New("insert")))
+// Patch implements CategoryService. The patched document is checked with
+// the rules of CategoryUpdateRequest and CategoryMoveRequest, so a patch is
+// accepted exactly when the equivalent PUT and move would be.
+func (c *CategoryServiceImpl) Patch(ctx context.Context, req *request.CategoryPatchRequest) (*response.CategoryResponse, error) {
+	errVal := helper.ValidateStruct(req, c.Validator)
+	if errVal != nil {
+		return nil, errVal
+	}
+
+	var result *domain.Category
+	err := c.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		findCategory, errFind := c.CategoryRepo.FindByIdForUpdate(ctx, req.Id)
+		if errFind != nil {
+			return errFind
+		}
+		errMatch := checkIfMatch(findCategory, req.IfMatch)
+		if errMatch != nil {
+			return errMatch
+		}
+
+		patched, errPatch := applyCategoryPatch(findCategory, req)
+		if errPatch != nil {
+			return errPatch
+		}
+
+		update := &request.CategoryUpdateRequest{Id: findCategory.Id, Name: patched.Name, Description: patched.Description}
+		errCheck := helper.ValidateStruct(update, c.Validator)
+		if errCheck != nil {
+			return errCheck
+		}
+		move := &request.CategoryMoveRequest{Id: findCategory.Id, ParentId: patched.ParentId}
+		errCheck = helper.ValidateStruct(move, c.Validator)
+		if errCheck != nil {
+			return errCheck
+		}
+
+		result = findCategory
+		if patched.Name != findCategory.Name || patched.Description != findCategory.Description {
+			var errUp error
+			result, errUp = c.CategoryRepo.Update(ctx, &domain.Category{Id: findCategory.Id, Name: patched.Name, Description: patched.Description})
+			if errUp != nil {
+				return errUp
+			}
+		}
+		if !sameParent(patched.ParentId, findCategory.ParentId) {
+			var errMove error
+			result, errMove = c.CategoryRepo.Move(ctx, findCategory.Id, patched.ParentId)
+			if errMove != nil {
+				return errMove
 			}
-		}(&outerError)
-		if outerError == nil {
-			break
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toCategoryResponse(result), nil
+}
+
+// applyCategoryPatch applies the patch in req to category and decodes the
+// result, rejecting changes to read-only members.
+func applyCategoryPatch(category *domain.Category, req *request.CategoryPatchRequest) (*categoryPatchDocument, error) {
+	current := &categoryPatchDocument{
+		Id:          category.Id,
+		Name:        category.Name,
+		Description: category.Description,
+		ParentId:    category.ParentId,
+		Version:     category.Version,
+	}
+	doc, errDoc := json.Marshal(current)
+	if errDoc != nil {
+		return nil, errDoc
+	}
+
+	raw, errApply := patch.Apply(req.ContentType, doc, req.Patch)
+	if errors.Is(errApply, patch.ErrTestFailed) {
+		return nil, domain.NewError(domain.ErrConflict, errApply.Error(), nil)
+	}
+	if errApply != nil {
+		return nil, domain.NewError(domain.ErrValidation, errApply.Error(), nil)
 	}
 
-	if counter%100 == 0 {
-		log.Println("=> worker", workerIndex, "inserted", counter, "data")
+	patched := &categoryPatchDocument{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	errDec := decoder.Decode(patched)
+	if errDec != nil {
+		return nil, domain.NewError(domain.ErrValidation, "patched category is invalid: "+errDec.Error(), nil)
 	}
+
+	var errFields []*domain.FieldError
+	if patched.Id != current.Id {
+		errFields = append(errFields, &domain.FieldError{Field: "id", Tag: "readonly"})
+	}
+	if patched.Version != current.Version {
+		errFields = append(errFields, &domain.FieldError{Field: "version", Tag: "readonly"})
+	}
+	if len(errFields) > 0 {
+		return nil, domain.NewValidationError(errFields)
+	}
+	return patched, nil
 }
 
-func (service *CategoryServiceImpl) OpenCsvFile(head *multipart.FileHeader) (*csv.Reader, error) {
-	file, errOpen := head.Open()
-	if errOpen != nil {
-		panic(helper.NewHTTPError(500, errOpen))
+func sameParent(a *int, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+var revisionDefaultSort = []domain.SortKey{{Field: "rev", Desc: true}}
+
+// History implements CategoryService. Revisions are listed newest first.
+func (c *CategoryServiceImpl) History(ctx context.Context, categoryId int, params *request.CategoryHistoryParams) (*response.CategoryRevisionPageResponse, error) {
+	errVal := helper.ValidateStruct(params, c.Validator)
+	if errVal != nil {
+		return nil, errVal
+	}
+
+	query, errQuery := newPageQuery(params.Cursor, params.Limit, false, revisionDefaultSort)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+
+	page, err := c.CategoryRepo.FindRevisions(ctx, categoryId, query)
+	if err != nil {
+		return nil, err
+	}
+	revisionResponses := []*response.CategoryRevisionResponse{}
+	for _, revision := range page.Revisions {
+		revisionResponses = append(revisionResponses, toCategoryRevisionResponse(revision))
 	}
 
-	reader := csv.NewReader(file)
-	return reader, nil
+	next, prev := pageCursors(query, page.Revisions, page.HasMore, func(revision *domain.CategoryRevision) []string {
+		return []string{strconv.Itoa(revision.Rev)}
+	})
+	return &response.CategoryRevisionPageResponse{
+		Data: revisionResponses,
+		Page: &response.PageResponse{Limit: query.Limit, NextCursor: next, PrevCursor: prev},
+	}, nil
 }
 
-func (service *CategoryServiceImpl) ReadCsvFilePerLineThenSendToWorker(csvReader *csv.Reader, jobs chan<- *domain.Category) {
-	isHeader := true
-	for {
-		row, err := csvReader.Read()
+// Revision implements CategoryService.
+func (c *CategoryServiceImpl) Revision(ctx context.Context, categoryId int, rev int) (*response.CategoryRevisionResponse, error) {
+	revision, err := c.CategoryRepo.FindRevision(ctx, categoryId, rev)
+	if err != nil {
+		return nil, err
+	}
+	return toCategoryRevisionResponse(revision), nil
+}
+
+// Revert implements CategoryService. The category gets the values it had
+// right after the revision, written through Update and Move so the usual
+// checks and a new revision apply. A category in the trash must be restored
+// first.
+func (c *CategoryServiceImpl) Revert(ctx context.Context, req *request.CategoryRevertRequest) (*response.CategoryResponse, error) {
+	errVal := helper.ValidateStruct(req, c.Validator)
+	if errVal != nil {
+		return nil, errVal
+	}
+
+	var result *response.CategoryResponse
+	err := c.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		revision, errFind := c.CategoryRepo.FindRevision(ctx, req.Id, req.Rev)
+		if errFind != nil {
+			return errFind
+		}
+
+		var errUp error
+		result, errUp = c.Update(ctx, &request.CategoryUpdateRequest{
+			Id:          req.Id,
+			Name:        revision.New.Name,
+			Description: revision.New.Description,
+			IfMatch:     req.IfMatch,
+		})
+		if errUp != nil {
+			return errUp
+		}
+
+		if !sameParent(result.ParentId, revision.New.ParentId) {
+			var errMove error
+			result, errMove = c.Move(ctx, &request.CategoryMoveRequest{Id: req.Id, ParentId: revision.New.ParentId})
+			return errMove
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func toCategoryRevisionResponse(revision *domain.CategoryRevision) *response.CategoryRevisionResponse {
+	return &response.CategoryRevisionResponse{
+		CategoryId: revision.CategoryId,
+		Rev:        revision.Rev,
+		Action:     revision.Action,
+		Old:        toCategorySnapshotResponse(revision.Old),
+		New:        toCategorySnapshotResponse(revision.New),
+		Actor:      revision.Actor,
+		RequestId:  revision.RequestId,
+		CreatedAt:  revision.CreatedAt,
+	}
+}
+
+func toCategorySnapshotResponse(snapshot *domain.CategorySnapshot) *response.CategorySnapshotResponse {
+	if snapshot == nil {
+		return nil
+	}
+	return &response.CategorySnapshotResponse{
+		Name:        snapshot.Name,
+		Description: snapshot.Description,
+		ParentId:    snapshot.ParentId,
+		DeletedAt:   snapshot.DeletedAt,
+	}
+}
+
+// ImportRows implements CategoryService. A row whose parent does not exist
+// yet is held back, its parent may come later in the file; held rows are
+// written once the file is drained, see importWaiting.
+func (service *CategoryServiceImpl) ImportRows(ctx context.Context, decoder codec.Decoder, options *domain.ImportOptions, progress *ImportProgress) error {
+	produce := func(send func(*domain.ImportRow) error) error {
+		return service.readFilePerLine(decoder, options, send, progress)
+	}
+
+	var mu sync.Mutex
+	var waiting []*domain.ImportRow
+	errRun := worker.Run(ctx, service.Pool, produce, func(ctx context.Context, workerIndex int, row *domain.ImportRow) {
+		outcome, attempts, err := service.importData(ctx, row, options.Mode)
+		if err != nil && ctx.Err() != nil {
+			// job dihentikan, row ini bukan gagal karena isinya
+			return
+		}
+		if isMissingParent(row, err) {
+			mu.Lock()
+			waiting = append(waiting, row)
+			mu.Unlock()
+			return
+		}
 		if err != nil {
-			if err == io.EOF {
-				err = nil
+			progress.DeadLetter(row, attempts, err)
+			return
+		}
+
+		counter := progress.Done(outcome)
+		if counter%100 == 0 {
+			log.Println("=> worker", workerIndex, "inserted", counter, "data")
+		}
+	})
+	if errRun != nil {
+		return errRun
+	}
+
+	return service.importWaiting(ctx, waiting, options.Mode, progress)
+}
+
+// importWaiting writes the rows held back for a missing parent, pass after
+// pass, as long as each pass writes at least one of them: a pass can create
+// the parents of the next. Rows left when a pass writes none are
+// dead-lettered.
+func (service *CategoryServiceImpl) importWaiting(ctx context.Context, waiting []*domain.ImportRow, mode string, progress *ImportProgress) error {
+	type heldRow struct {
+		row      *domain.ImportRow
+		attempts int
+		err      error
+	}
+	for len(waiting) > 0 {
+		var held []*heldRow
+		for _, row := range waiting {
+			outcome, attempts, err := service.importData(ctx, row, mode)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if isMissingParent(row, err) {
+				held = append(held, &heldRow{row: row, attempts: attempts, err: err})
+				continue
 			}
+			if err != nil {
+				progress.DeadLetter(row, attempts, err)
+				continue
+			}
+			progress.Done(outcome)
+		}
+
+		if len(held) == len(waiting) {
+			for _, row := range held {
+				progress.DeadLetter(row.row, row.attempts, row.err)
+			}
+			return nil
+		}
+		waiting = waiting[:0]
+		for _, row := range held {
+			waiting = append(waiting, row.row)
+		}
+	}
+	return nil
+}
+
+func isMissingParent(row *domain.ImportRow, err error) bool {
+	return row.Parent != "" && errors.Is(err, domain.ErrNotFound)
+}
+
+// ImportRow implements CategoryService.
+func (c *CategoryServiceImpl) ImportRow(ctx context.Context, row *domain.ImportRow, mode string) (*response.CategoryResponse, error) {
+	errVal := helper.ValidateStruct(&request.CategoryCreateRequest{
+		Name:        row.Category.Name,
+		Description: row.Category.Description,
+		Parent:      row.Parent,
+	}, c.Validator)
+	if errVal != nil {
+		return nil, errVal
+	}
+
+	// row yang di-skip tetap mengembalikan category yang sudah ada
+	_, category, errRow := c.CategoryRepo.ImportRow(ctx, row, mode)
+	if errRow != nil {
+		return nil, errRow
+	}
+	if category == nil {
+		return nil, domain.NewError(domain.ErrNotFound, "category not found", nil)
+	}
+	return c.FindById(ctx, category.Id)
+}
+
+// importData writes one row, retrying transient database errors with
+// backoff. It returns what happened to the row, the number of attempts made
+// and the last error.
+func (service *CategoryServiceImpl) importData(ctx context.Context, row *domain.ImportRow, mode string) (string, int, error) {
+	var outcome string
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		outcome, _, err = service.CategoryRepo.ImportRow(ctx, row, mode)
+		if err == nil || !helper.IsRetryable(err) || attempt >= service.Retry.MaxAttempts {
 			break
 		}
 
-		if isHeader {
-			isHeader = false
+		select {
+		case <-time.After(service.Retry.Backoff(attempt)):
+		case <-ctx.Done():
+			return "", attempt, ctx.Err()
+		}
+	}
+
+	return outcome, attempt, err
+}
+
+func (service *CategoryServiceImpl) readFilePerLine(decoder codec.Decoder, options *domain.ImportOptions, send func(*domain.ImportRow) error, progress *ImportProgress) error {
+	columns, errHead := readCategoryColumns(decoder, options.Mapping)
+	if errHead == io.EOF {
+		return nil
+	}
+	if errHead != nil {
+		return errHead
+	}
+
+	for {
+		row, rowError, err := readImportRecord(decoder)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if rowError != nil {
+			progress.Fail(rowError)
 			continue
 		}
 
-		rowData := &domain.Category{
-			Name:        row[0],
-			Description: row[1],
+		line := decoder.Line()
+		importRow, rowErrors := parseCategoryRow(service.Validator, columns, line, row)
+		if rowErrors != nil {
+			progress.Fail(rowErrors...)
+			continue
 		}
 
-		service.Wg.Add(1)
-		jobs <- rowData
+		errSend := send(importRow)
+		if errSend != nil {
+			return errSend
+		}
 	}
-
-	close(jobs)
 }
@@ -0,0 +1,409 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/daint23/gofiberpg/src/codec"
+	"github.com/daint23/gofiberpg/src/domain"
+	"github.com/daint23/gofiberpg/src/helper"
+	"github.com/daint23/gofiberpg/src/http/request"
+	"github.com/go-playground/validator/v10"
+)
+
+var categoryCsvColumns = []string{"name", "description", "parent"}
+
+var categoryRequiredColumns = []string{"name"}
+
+// categoryColumns maps a category field to its index in the decoded rows.
+type categoryColumns map[string]int
+
+// newCategoryColumns resolves the header row of an upload, renaming headers
+// through mapping first. Every problem with the header is reported at once
+// as an input validation error.
+func newCategoryColumns(header []string, mapping map[string]string) (categoryColumns, error) {
+	var errFields []*domain.FieldError
+	for _, field := range mapping {
+		if !slices.Contains(categoryCsvColumns, field) {
+			errFields = append(errFields, &domain.FieldError{Field: field, Tag: "unknown_field"})
+		}
+	}
+	if len(errFields) > 0 {
+		return nil, domain.NewValidationError(errFields)
+	}
+
+	columns := categoryColumns{}
+	found := map[string]bool{}
+	for idx, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		found[name] = true
+
+		field := name
+		if target, ok := mapping[name]; ok {
+			field = target
+		}
+
+		if !slices.Contains(categoryCsvColumns, field) {
+			errFields = append(errFields, &domain.FieldError{Field: name, Tag: "unknown_column"})
+			continue
+		}
+		if _, dup := columns[field]; dup {
+			errFields = append(errFields, &domain.FieldError{Field: name, Tag: "duplicate_column", Param: field})
+			continue
+		}
+		columns[field] = idx
+	}
+
+	for source := range mapping {
+		if !found[source] {
+			errFields = append(errFields, &domain.FieldError{Field: source, Tag: "missing_column"})
+		}
+	}
+	for _, field := range categoryRequiredColumns {
+		if _, ok := columns[field]; !ok {
+			errFields = append(errFields, &domain.FieldError{Field: field, Tag: "required_column"})
+		}
+	}
+
+	if len(errFields) > 0 {
+		return nil, domain.NewValidationError(errFields)
+	}
+	return columns, nil
+}
+
+// categoryImportKeys is the header of an upload whose records name their
+// fields: the category columns, with the sources of mapping in place of the
+// columns they are mapped to.
+func categoryImportKeys(mapping map[string]string) []string {
+	sources := make([]string, 0, len(mapping))
+	for source := range mapping {
+		sources = append(sources, source)
+	}
+	slices.Sort(sources)
+
+	var keys []string
+	for _, field := range categoryCsvColumns {
+		mapped := false
+		for _, source := range sources {
+			if mapping[source] == field {
+				keys = append(keys, source)
+				mapped = true
+			}
+		}
+		if !mapped {
+			keys = append(keys, field)
+		}
+	}
+	return keys
+}
+
+// readCategoryColumns reads the header row from decoder. It returns io.EOF
+// for an empty upload.
+func readCategoryColumns(decoder codec.Decoder, mapping map[string]string) (categoryColumns, error) {
+	header, errHead := decoder.Read()
+	if errHead == io.EOF {
+		return nil, errHead
+	}
+	if errHead != nil {
+		return nil, domain.NewError(domain.ErrValidation, "cannot read header: "+errHead.Error(), nil)
+	}
+
+	return newCategoryColumns(header, mapping)
+}
+
+// parseColumnMapping parses the mapping query option, e.g.
+// "title:name,summary:description".
+func parseColumnMapping(raw string) (map[string]string, error) {
+	mapping := map[string]string{}
+	if strings.TrimSpace(raw) == "" {
+		return mapping, nil
+	}
+
+	var errFields []*domain.FieldError
+	for _, pair := range strings.Split(raw, ",") {
+		source, target, ok := strings.Cut(pair, ":")
+		source = strings.ToLower(strings.TrimSpace(source))
+		target = strings.ToLower(strings.TrimSpace(target))
+		if !ok || source == "" || target == "" {
+			errFields = append(errFields, &domain.FieldError{Field: "mapping", Tag: "format", Param: pair})
+			continue
+		}
+		mapping[source] = target
+	}
+
+	if len(errFields) > 0 {
+		return nil, domain.NewValidationError(errFields)
+	}
+	return mapping, nil
+}
+
+// parseCategoryRow checks a row against the same rules as
+// CategoryCreateRequest, so an uploaded row is accepted exactly when the
+// equivalent POST /categories would be.
+func parseCategoryRow(validate *validator.Validate, columns categoryColumns, line int, row []string) (*domain.ImportRow, []*domain.ImportRowError) {
+	values := map[string]string{}
+	for _, field := range categoryCsvColumns {
+		idx, ok := columns[field]
+		if !ok {
+			continue
+		}
+		if idx >= len(row) {
+			return nil, []*domain.ImportRowError{{
+				Line:    line,
+				Column:  field,
+				Message: fmt.Sprintf("expected at least %d columns, got %d", idx+1, len(row)),
+			}}
+		}
+		values[field] = row[idx]
+	}
+
+	req := &request.CategoryCreateRequest{
+		Name:        values["name"],
+		Description: values["description"],
+		Parent:      values["parent"],
+	}
+
+	var rowErrors []*domain.ImportRowError
+	for _, errField := range helper.ValidationErrors(req, validate) {
+		message := errField.Tag
+		if errField.Param != "" {
+			message += "=" + errField.Param
+		}
+		rowErrors = append(rowErrors, &domain.ImportRowError{Line: line, Column: errField.Field, Message: message})
+	}
+	if len(rowErrors) > 0 {
+		return nil, rowErrors
+	}
+
+	return &domain.ImportRow{
+		Line:     line,
+		Category: &domain.Category{Name: req.Name, Description: req.Description},
+		Parent:   normalizeCategoryPath(values["parent"]),
+	}, nil
+}
+
+// normalizeCategoryPath trims the segments of a parent reference, so
+// " Shoes / Running " becomes "Shoes/Running".
+func normalizeCategoryPath(path string) string {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		segment = strings.TrimSpace(segment)
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// newImportOptions validates the query options of an upload and fills in
+// the defaults.
+func newImportOptions(req *request.ImportRequest, validate *validator.Validate) (*domain.ImportOptions, error) {
+	errVal := helper.ValidateStruct(req, validate)
+	if errVal != nil {
+		return nil, errVal
+	}
+
+	mapping, errMap := parseColumnMapping(req.Mapping)
+	if errMap != nil {
+		return nil, errMap
+	}
+
+	codecOptions, errFields := newCodecOptions(req.Delimiter, req.Quote, false)
+	if req.Format != "" {
+		_, errFormat := codec.Lookup(req.Format)
+		if errFormat != nil {
+			errFields = append(errFields, &domain.FieldError{Field: "format", Tag: "oneof", Param: formatNames()})
+		}
+	}
+	if len(errFields) > 0 {
+		return nil, domain.NewValidationError(errFields)
+	}
+
+	options := &domain.ImportOptions{
+		Mode:      req.Mode,
+		DryRun:    req.DryRun,
+		Mapping:   mapping,
+		Format:    req.Format,
+		Delimiter: codecOptions.Delimiter,
+		Quote:     codecOptions.Quote,
+	}
+	if options.Mode == "" {
+		options.Mode = domain.ImportModeInsert
+	}
+	return options, nil
+}
+
+// newExportFormat resolves the format of a download from the format query
+// option, then the Accept header, then csv.
+func newExportFormat(req *request.ExportRequest, accept string) (*codec.Format, *codec.Options, error) {
+	codecOptions, errFields := newCodecOptions(req.Delimiter, req.Quote, req.Bom)
+
+	format := codec.Negotiate(accept)
+	if req.Format != "" {
+		var errFormat error
+		format, errFormat = codec.Lookup(req.Format)
+		if errFormat != nil {
+			errFields = append(errFields, &domain.FieldError{Field: "format", Tag: "oneof", Param: formatNames()})
+		}
+	}
+	if len(errFields) > 0 {
+		return nil, nil, domain.NewValidationError(errFields)
+	}
+
+	if format == nil {
+		format = codec.CSV
+	}
+	return format, codecOptions, nil
+}
+
+// newCodecOptions checks the delimiter and quote query options. Both take a
+// single character; the delimiter also accepts "tab".
+func newCodecOptions(delimiter string, quote string, bom bool) (*codec.Options, []*domain.FieldError) {
+	var errFields []*domain.FieldError
+	options := &codec.Options{BOM: bom}
+
+	if delimiter == "tab" {
+		delimiter = "\t"
+	}
+	if delimiter != "" {
+		runes := []rune(delimiter)
+		if len(runes) != 1 || runes[0] == '\r' || runes[0] == '\n' || runes[0] == '"' {
+			errFields = append(errFields, &domain.FieldError{Field: "delimiter", Tag: "len", Param: "1"})
+		} else {
+			options.Delimiter = runes[0]
+		}
+	}
+
+	if quote != "" {
+		if len(quote) != 1 || quote[0] >= 0x80 || quote[0] == '\r' || quote[0] == '\n' || rune(quote[0]) == options.Delimiter {
+			errFields = append(errFields, &domain.FieldError{Field: "quote", Tag: "len", Param: "1"})
+		} else {
+			options.Quote = quote[0]
+		}
+	}
+
+	return options, errFields
+}
+
+func formatNames() string {
+	return strings.Join([]string{codec.CSV.Name, codec.TSV.Name, codec.NDJSON.Name, codec.XLSX.Name}, " ")
+}
+
+// openImportDecoder opens an upload with the format given in options, or
+// the one detected from its content type and file name.
+func openImportDecoder(r io.Reader, contentType string, fileName string, options *domain.ImportOptions) (codec.Decoder, error) {
+	format := codec.Detect(contentType, fileName)
+	if options.Format != "" {
+		format, _ = codec.Lookup(options.Format)
+	}
+
+	codecOptions := &codec.Options{
+		Delimiter: options.Delimiter,
+		Quote:     options.Quote,
+		Keys:      categoryImportKeys(options.Mapping),
+	}
+	decoder, err := format.NewDecoder(r, codecOptions)
+	if err != nil {
+		return nil, domain.NewError(domain.ErrValidation, fmt.Sprintf("cannot read %s file: %v", format.Name, err), nil)
+	}
+	return decoder, nil
+}
+
+// readImportRecord reads the next data record. A record that cannot be
+// decoded is returned as a row error so the rest of the file can still be
+// imported.
+func readImportRecord(decoder codec.Decoder) ([]string, *domain.ImportRowError, error) {
+	record, err := decoder.Read()
+	var recordErr *codec.RecordError
+	if errors.As(err, &recordErr) {
+		return nil, &domain.ImportRowError{Line: recordErr.Line, Message: recordErr.Err.Error()}, nil
+	}
+	return record, nil, err
+}
+
+// maxImportErrors caps the rows whose errors one import keeps. Rows past it
+// are still counted as failed, but their errors are dropped, so a file full
+// of bad rows does not fill memory.
+const maxImportErrors = 1000
+
+// importErrors collects the errors of the rows an import rejects.
+type importErrors struct {
+	errors []*domain.ImportRowError
+	rows   int
+}
+
+// add records the errors of one rejected row.
+func (e *importErrors) add(rowErrors ...*domain.ImportRowError) {
+	e.rows++
+	if e.rows <= maxImportErrors {
+		e.errors = append(e.errors, rowErrors...)
+	}
+}
+
+// firstRowErrors keeps the errors of the first limit rows of rowErrors,
+// which is sorted by line.
+func firstRowErrors(rowErrors []*domain.ImportRowError, limit int) []*domain.ImportRowError {
+	rows := 0
+	for idx, rowError := range rowErrors {
+		if idx == 0 || rowError.Line != rowErrors[idx-1].Line {
+			rows++
+		}
+		if rows > limit {
+			return rowErrors[:idx]
+		}
+	}
+	return rowErrors
+}
+
+// categoryRowSource feeds decoded rows to pgx CopyFrom one line at a time,
+// so the upload is never held in memory as a whole. Rows that fail
+// validation are skipped and collected in errors.
+type categoryRowSource struct {
+	decoder  codec.Decoder
+	validate *validator.Validate
+	columns  categoryColumns
+	values   []interface{}
+	errors   importErrors
+	err      error
+}
+
+func newCategoryRowSource(decoder codec.Decoder, validate *validator.Validate, columns categoryColumns) *categoryRowSource {
+	return &categoryRowSource{decoder: decoder, validate: validate, columns: columns}
+}
+
+func (s *categoryRowSource) Next() bool {
+	for {
+		row, rowError, err := readImportRecord(s.decoder)
+		if err != nil {
+			if err != io.EOF {
+				s.err = err
+			}
+			return false
+		}
+		if rowError != nil {
+			s.errors.add(rowError)
+			continue
+		}
+
+		line := s.decoder.Line()
+		importRow, rowErrors := parseCategoryRow(s.validate, s.columns, line, row)
+		if rowErrors != nil {
+			s.errors.add(rowErrors...)
+			continue
+		}
+
+		s.values = []interface{}{line, importRow.Category.Name, importRow.Category.Description, importRow.Parent}
+		return true
+	}
+}
+
+func (s *categoryRowSource) Values() ([]interface{}, error) {
+	return s.values, nil
+}
+
+func (s *categoryRowSource) Err() error {
+	return s.err
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/daint23/gofiberpg/src/domain"
+)
+
+// parseFilters reads field[operator]=value options from a query string,
+// skipping the keys in reserved. A bare field=value means eq and in takes a
+// comma separated list, e.g. id[in]=1,2,3. Whether a field or operator is
+// allowed is up to the repo.
+func parseFilters(values url.Values, reserved ...string) ([]domain.Filter, error) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		if !slices.Contains(reserved, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var filters []domain.Filter
+	var errFields []*domain.FieldError
+	for _, key := range keys {
+		field, operator, ok := parseFilterKey(key)
+		if !ok {
+			errFields = append(errFields, &domain.FieldError{Field: key, Tag: "format"})
+			continue
+		}
+
+		for _, value := range values[key] {
+			filter := domain.Filter{Field: field, Operator: operator, Values: []string{value}}
+			if operator == domain.FilterIn {
+				filter.Values = strings.Split(value, ",")
+			}
+			filters = append(filters, filter)
+		}
+	}
+
+	if len(errFields) > 0 {
+		return nil, domain.NewValidationError(errFields)
+	}
+	return filters, nil
+}
+
+// parseFilterKey splits "name[ilike]" into its field and operator.
+func parseFilterKey(key string) (string, string, bool) {
+	field, rest, bracket := strings.Cut(key, "[")
+	if !bracket {
+		return key, domain.FilterEq, key != ""
+	}
+
+	operator, ok := strings.CutSuffix(rest, "]")
+	if !ok || field == "" || operator == "" || strings.ContainsAny(operator, "[]") {
+		return "", "", false
+	}
+	return field, strings.ToLower(operator), true
+}
+
+// parseSort reads the sort option, e.g. "-name,id". A leading - sorts
+// descending. id is appended when missing so the order is total, which
+// keyset paging relies on.
+func parseSort(raw string, defaultSort []domain.SortKey) ([]domain.SortKey, error) {
+	if strings.TrimSpace(raw) == "" {
+		return defaultSort, nil
+	}
+
+	var keys []domain.SortKey
+	var errFields []*domain.FieldError
+	seen := map[string]bool{}
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		key := domain.SortKey{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		if key.Field == "" {
+			errFields = append(errFields, &domain.FieldError{Field: "sort", Tag: "format", Param: raw})
+			continue
+		}
+		if seen[key.Field] {
+			errFields = append(errFields, &domain.FieldError{Field: "sort", Tag: "duplicate", Param: key.Field})
+			continue
+		}
+		seen[key.Field] = true
+		keys = append(keys, key)
+	}
+
+	if len(errFields) > 0 {
+		return nil, domain.NewValidationError(errFields)
+	}
+	if !seen["id"] {
+		keys = append(keys, domain.SortKey{Field: "id"})
+	}
+	return keys, nil
+}
@@ -0,0 +1,521 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/daint23/gofiberpg/src/codec"
+	"github.com/daint23/gofiberpg/src/database"
+	"github.com/daint23/gofiberpg/src/domain"
+	"github.com/daint23/gofiberpg/src/helper"
+	"github.com/daint23/gofiberpg/src/http/request"
+	"github.com/daint23/gofiberpg/src/http/response"
+	"github.com/daint23/gofiberpg/src/repo"
+	"github.com/go-playground/validator/v10"
+)
+
+// ImportProgress tracks the rows of a single import job while the workers
+// are running.
+type ImportProgress struct {
+	Processed atomic.Int64
+	Inserted  atomic.Int64
+	Updated   atomic.Int64
+	Skipped   atomic.Int64
+	Failed    atomic.Int64
+
+	mu       sync.Mutex
+	errors   importErrors
+	failures []*domain.ImportFailure
+}
+
+// Done records a row that was written or skipped and returns the number of
+// rows processed so far.
+func (p *ImportProgress) Done(outcome string) int64 {
+	switch outcome {
+	case domain.RowInserted:
+		p.Inserted.Add(1)
+	case domain.RowUpdated:
+		p.Updated.Add(1)
+	default:
+		p.Skipped.Add(1)
+	}
+	return p.Processed.Add(1)
+}
+
+// Fill copies the counters into job.
+func (p *ImportProgress) Fill(job *domain.ImportJob) {
+	job.RowsProcessed = int(p.Processed.Load())
+	job.RowsInserted = int(p.Inserted.Load())
+	job.RowsUpdated = int(p.Updated.Load())
+	job.RowsSkipped = int(p.Skipped.Load())
+	job.RowsFailed = int(p.Failed.Load())
+}
+
+// Fail records the errors of one rejected row.
+func (p *ImportProgress) Fail(rowErrors ...*domain.ImportRowError) {
+	p.Processed.Add(1)
+	p.Failed.Add(1)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.errors.add(rowErrors...)
+}
+
+// DeadLetter records a row whose insert still failed after all attempts.
+func (p *ImportProgress) DeadLetter(row *domain.ImportRow, attempts int, err error) {
+	p.Fail(&domain.ImportRowError{Line: row.Line, Message: err.Error()})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failures = append(p.failures, &domain.ImportFailure{
+		Line:        row.Line,
+		Name:        row.Category.Name,
+		Description: row.Category.Description,
+		Parent:      row.Parent,
+		Error:       err.Error(),
+		Attempts:    attempts,
+	})
+}
+
+func (p *ImportProgress) Failures() []*domain.ImportFailure {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.failures
+}
+
+// Errors returns the errors of the first rejected rows, see
+// maxImportErrors; Failed counts them all.
+func (p *ImportProgress) Errors() []*domain.ImportRowError {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.errors.errors
+}
+
+type ImportJobService interface {
+	Insert(ctx context.Context, head *multipart.FileHeader, req *request.ImportRequest) (*response.ImportJobResponse, error)
+	FindById(ctx context.Context, jobId int) (*response.ImportJobResponse, error)
+	FindAll(ctx context.Context, params *request.ImportJobQueryParams) ([]*response.ImportJobResponse, error)
+	FindErrors(ctx context.Context, jobId int) ([]*response.ImportRowErrorResponse, error)
+	FindFailures(ctx context.Context, params *request.ImportFailureQueryParams) ([]*response.ImportFailureResponse, error)
+	ReplayFailure(ctx context.Context, failureId int) (*response.CategoryResponse, error)
+	MarkInterrupted(ctx context.Context)
+	// MarkInterruptedEvery runs MarkInterrupted now and then every interval
+	// until ctx is done.
+	MarkInterruptedEvery(ctx context.Context, interval time.Duration)
+	// Wait blocks until every job started by Insert has stopped. Jobs stop
+	// when Lifetime is done and are saved as failed.
+	Wait()
+}
+
+type ImportJobServiceImpl struct {
+	ImportJobRepo     repo.ImportJobRepo
+	ImportFailureRepo repo.ImportFailureRepo
+	CategoryService   CategoryService
+	TxManager         database.TxManager
+	Validator         *validator.Validate
+	// InstanceId owns the jobs started by this process.
+	InstanceId string
+	// StaleAfter is how long a running job may go without a heartbeat
+	// before MarkInterrupted fails it.
+	StaleAfter time.Duration
+	// Lifetime is the context jobs run in, done when the server shuts down.
+	Lifetime context.Context
+
+	jobs sync.WaitGroup
+}
+
+func NewImportJobService(lifetime context.Context, importJobRepo repo.ImportJobRepo, importFailureRepo repo.ImportFailureRepo, categoryService CategoryService, txManager database.TxManager, validator *validator.Validate, instanceId string, staleAfter time.Duration) ImportJobService {
+	return &ImportJobServiceImpl{
+		Lifetime:          lifetime,
+		ImportJobRepo:     importJobRepo,
+		ImportFailureRepo: importFailureRepo,
+		CategoryService:   categoryService,
+		TxManager:         txManager,
+		Validator:         validator,
+		InstanceId:        instanceId,
+		StaleAfter:        staleAfter,
+	}
+}
+
+// Insert implements ImportJobService.
+func (s *ImportJobServiceImpl) Insert(ctx context.Context, head *multipart.FileHeader, req *request.ImportRequest) (*response.ImportJobResponse, error) {
+	options, errOpt := newImportOptions(req, s.Validator)
+	if errOpt != nil {
+		return nil, errOpt
+	}
+	if options.DryRun {
+		return nil, domain.NewError(domain.ErrValidation, "dryRun is only supported by POST /categories/export", nil)
+	}
+	if s.Lifetime.Err() != nil {
+		return nil, domain.NewError(domain.ErrUnavailable, "server is shutting down", nil)
+	}
+
+	// upload harus disalin dulu, file multipart hilang setelah request selesai
+	path, errSave := saveUpload(head)
+	if errSave != nil {
+		return nil, errSave
+	}
+
+	if options.Format == "" {
+		options.Format = codec.Detect(head.Header.Get("Content-Type"), head.Filename).Name
+	}
+
+	errHead := checkUploadHeader(path, options)
+	if errHead != nil {
+		os.Remove(path)
+		return nil, errHead
+	}
+
+	job, errIn := s.ImportJobRepo.Insert(ctx, &domain.ImportJob{
+		Status:   domain.ImportJobQueued,
+		Mode:     options.Mode,
+		FileName: head.Filename,
+		Owner:    s.InstanceId,
+	})
+	if errIn != nil {
+		os.Remove(path)
+		return nil, errIn
+	}
+
+	result := toImportJobResponse(job)
+	// ctx request sudah selesai saat job jalan, actor disalin ke context milik server
+	s.jobs.Add(1)
+	go func() {
+		defer s.jobs.Done()
+		s.run(helper.Detach(s.Lifetime, ctx), job, path, options)
+	}()
+
+	return result, nil
+}
+
+// FindById implements ImportJobService.
+func (s *ImportJobServiceImpl) FindById(ctx context.Context, jobId int) (*response.ImportJobResponse, error) {
+	job, err := s.ImportJobRepo.FindById(ctx, jobId)
+	if err != nil {
+		return nil, err
+	}
+	return toImportJobResponse(job), nil
+}
+
+// FindAll implements ImportJobService.
+func (s *ImportJobServiceImpl) FindAll(ctx context.Context, params *request.ImportJobQueryParams) ([]*response.ImportJobResponse, error) {
+	if params.Limit <= 0 || params.Limit > 100 {
+		params.Limit = 20
+	}
+
+	jobs, err := s.ImportJobRepo.FindAll(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	jobResponses := []*response.ImportJobResponse{}
+	for _, job := range jobs {
+		jobResponses = append(jobResponses, toImportJobResponse(job))
+	}
+	return jobResponses, nil
+}
+
+// FindErrors implements ImportJobService.
+func (s *ImportJobServiceImpl) FindErrors(ctx context.Context, jobId int) ([]*response.ImportRowErrorResponse, error) {
+	job, errFind := s.ImportJobRepo.FindById(ctx, jobId)
+	if errFind != nil {
+		return nil, errFind
+	}
+
+	rowErrors, err := s.ImportJobRepo.FindErrors(ctx, job.Id)
+	if err != nil {
+		return nil, err
+	}
+	return ToImportRowErrorResponses(rowErrors), nil
+}
+
+// FindFailures implements ImportJobService.
+func (s *ImportJobServiceImpl) FindFailures(ctx context.Context, params *request.ImportFailureQueryParams) ([]*response.ImportFailureResponse, error) {
+	if params.Limit <= 0 || params.Limit > 100 {
+		params.Limit = 20
+	}
+
+	failures, err := s.ImportFailureRepo.FindAll(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	failureResponses := []*response.ImportFailureResponse{}
+	for _, failure := range failures {
+		failureResponses = append(failureResponses, toImportFailureResponse(failure))
+	}
+	return failureResponses, nil
+}
+
+// ReplayFailure implements ImportJobService.
+func (s *ImportJobServiceImpl) ReplayFailure(ctx context.Context, failureId int) (*response.CategoryResponse, error) {
+	failure, errFind := s.ImportFailureRepo.FindById(ctx, failureId)
+	if errFind != nil {
+		return nil, errFind
+	}
+
+	// category dan tanda replayed disimpan dalam satu transaksi
+	var result *response.CategoryResponse
+	err := s.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		// ditandai dulu: replay lain dari failure yang sama menunggu lock ini lalu dapat conflict
+		errMark := s.ImportFailureRepo.MarkReplayed(ctx, failure.Id)
+		if errMark != nil {
+			return errMark
+		}
+
+		var errIn error
+		if failure.Mode == domain.ImportModeInsert {
+			result, errIn = s.CategoryService.Insert(ctx, &request.CategoryCreateRequest{
+				Name:        failure.Name,
+				Description: failure.Description,
+				Parent:      failure.Parent,
+			})
+			return errIn
+		}
+
+		result, errIn = s.CategoryService.ImportRow(ctx, &domain.ImportRow{
+			Line:     failure.Line,
+			Category: &domain.Category{Name: failure.Name, Description: failure.Description},
+			Parent:   failure.Parent,
+		}, failure.Mode)
+		return errIn
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// MarkInterrupted implements ImportJobService. Jobs of other instances
+// that still send heartbeats are left running.
+func (s *ImportJobServiceImpl) MarkInterrupted(ctx context.Context) {
+	total, err := s.ImportJobRepo.MarkInterrupted(ctx, s.StaleAfter)
+	if err != nil {
+		log.Println("=> import job: mark interrupted:", err)
+		return
+	}
+	if total > 0 {
+		log.Println("=> import job:", total, "jobs interrupted, their owner stopped responding")
+	}
+}
+
+// MarkInterruptedEvery implements ImportJobService.
+func (s *ImportJobServiceImpl) MarkInterruptedEvery(ctx context.Context, interval time.Duration) {
+	s.MarkInterrupted(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.MarkInterrupted(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Wait implements ImportJobService.
+func (s *ImportJobServiceImpl) Wait() {
+	s.jobs.Wait()
+}
+
+// run processes one job. When ctx is done before the file is, the job is
+// saved as failed with what it wrote so far.
+func (s *ImportJobServiceImpl) run(ctx context.Context, job *domain.ImportJob, path string, options *domain.ImportOptions) {
+	defer os.Remove(path)
+
+	startedAt := time.Now()
+	job.Status = domain.ImportJobRunning
+	job.StartedAt = &startedAt
+	s.save(ctx, job)
+
+	progress := &ImportProgress{}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func(snapshot domain.ImportJob) {
+		defer close(stopped)
+		s.reportProgress(ctx, &snapshot, progress, done)
+	}(*job)
+
+	errRun := s.process(ctx, path, options, progress)
+	close(done)
+	<-stopped
+
+	// hasil job tetap disimpan walau server sedang berhenti
+	interrupted := ctx.Err() != nil
+	ctx = context.WithoutCancel(ctx)
+	if interrupted {
+		errRun = errors.New("interrupted, server shut down")
+	}
+
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	progress.Fill(job)
+	job.Status = domain.ImportJobSucceeded
+
+	errSave := s.ImportJobRepo.InsertErrors(ctx, job.Id, progress.Errors())
+	if errSave != nil && errRun == nil {
+		errRun = errSave
+	}
+
+	failures := progress.Failures()
+	for _, failure := range failures {
+		failure.JobId = job.Id
+		failure.Mode = options.Mode
+	}
+	errDead := s.ImportFailureRepo.InsertAll(ctx, failures)
+	if errDead != nil && errRun == nil {
+		errRun = errDead
+	}
+	if errRun != nil {
+		job.Status = domain.ImportJobFailed
+		job.Error = errRun.Error()
+	}
+	s.save(ctx, job)
+}
+
+func (s *ImportJobServiceImpl) process(ctx context.Context, path string, options *domain.ImportOptions, progress *ImportProgress) error {
+	file, errOpen := os.Open(path)
+	if errOpen != nil {
+		return errOpen
+	}
+	defer file.Close()
+
+	decoder, errDec := openImportDecoder(file, "", path, options)
+	if errDec != nil {
+		return errDec
+	}
+
+	return s.CategoryService.ImportRows(ctx, decoder, options, progress)
+}
+
+func (s *ImportJobServiceImpl) reportProgress(ctx context.Context, job *domain.ImportJob, progress *ImportProgress, done <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			progress.Fill(job)
+			s.save(ctx, job)
+		}
+	}
+}
+
+func (s *ImportJobServiceImpl) save(ctx context.Context, job *domain.ImportJob) {
+	err := s.ImportJobRepo.Update(ctx, job)
+	if err != nil {
+		log.Println("=> import job", job.Id, "update failed:", err)
+	}
+}
+
+func saveUpload(head *multipart.FileHeader) (string, error) {
+	src, errOpen := head.Open()
+	if errOpen != nil {
+		return "", errOpen
+	}
+	defer src.Close()
+
+	dst, errCr := os.CreateTemp("", "category-import-*"+filepath.Ext(head.Filename))
+	if errCr != nil {
+		return "", errCr
+	}
+	defer dst.Close()
+
+	_, errCopy := io.Copy(dst, src)
+	if errCopy != nil {
+		os.Remove(dst.Name())
+		return "", errCopy
+	}
+
+	return dst.Name(), nil
+}
+
+// checkUploadHeader rejects an upload with unusable columns before a job is
+// created for it.
+func checkUploadHeader(path string, options *domain.ImportOptions) error {
+	file, errOpen := os.Open(path)
+	if errOpen != nil {
+		return errOpen
+	}
+	defer file.Close()
+
+	decoder, errDec := openImportDecoder(file, "", path, options)
+	if errDec != nil {
+		return errDec
+	}
+
+	_, errHead := readCategoryColumns(decoder, options.Mapping)
+	if errHead == io.EOF {
+		return nil
+	}
+	return errHead
+}
+
+func toImportJobResponse(job *domain.ImportJob) *response.ImportJobResponse {
+	result := &response.ImportJobResponse{
+		Id:            job.Id,
+		Status:        job.Status,
+		Mode:          job.Mode,
+		FileName:      job.FileName,
+		RowsProcessed: job.RowsProcessed,
+		RowsInserted:  job.RowsInserted,
+		RowsUpdated:   job.RowsUpdated,
+		RowsSkipped:   job.RowsSkipped,
+		RowsFailed:    job.RowsFailed,
+		Error:         job.Error,
+		Owner:         job.Owner,
+		CreatedAt:     job.CreatedAt,
+		UpdatedAt:     job.UpdatedAt,
+		StartedAt:     job.StartedAt,
+		FinishedAt:    job.FinishedAt,
+	}
+
+	if job.StartedAt != nil {
+		end := time.Now()
+		if job.FinishedAt != nil {
+			end = *job.FinishedAt
+		}
+		result.DurationMs = end.Sub(*job.StartedAt).Milliseconds()
+	}
+
+	return result
+}
+
+func ToImportRowErrorResponses(rowErrors []*domain.ImportRowError) []*response.ImportRowErrorResponse {
+	rowErrorResponses := []*response.ImportRowErrorResponse{}
+	for _, rowError := range rowErrors {
+		rowErrorResponses = append(rowErrorResponses, &response.ImportRowErrorResponse{
+			Line:    rowError.Line,
+			Column:  rowError.Column,
+			Message: rowError.Message,
+		})
+	}
+	return rowErrorResponses
+}
+
+func toImportFailureResponse(failure *domain.ImportFailure) *response.ImportFailureResponse {
+	return &response.ImportFailureResponse{
+		Id:          failure.Id,
+		JobId:       failure.JobId,
+		Line:        failure.Line,
+		Name:        failure.Name,
+		Description: failure.Description,
+		Parent:      failure.Parent,
+		Mode:        failure.Mode,
+		Error:       failure.Error,
+		Attempts:    failure.Attempts,
+		CreatedAt:   failure.CreatedAt,
+		ReplayedAt:  failure.ReplayedAt,
+	}
+}
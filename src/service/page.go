@@ -0,0 +1,100 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/daint23/gofiberpg/src/domain"
+)
+
+// pageCursor is the content of the opaque cursor handed to clients. Sort
+// records the order it was made for, so it cannot be reused with another.
+type pageCursor struct {
+	Sort     string   `json:"s"`
+	Values   []string `json:"v"`
+	Backward bool     `json:"b,omitempty"`
+}
+
+func encodeCursor(sort []domain.SortKey, values []string, backward bool) *string {
+	raw, _ := json.Marshal(&pageCursor{Sort: sortString(sort), Values: values, Backward: backward})
+	cursor := base64.RawURLEncoding.EncodeToString(raw)
+	return &cursor
+}
+
+// newPageQuery turns the paging query options into a PageQuery, applying the
+// default and maximum limit.
+func newPageQuery(cursor string, limit int, includeTotal bool, sort []domain.SortKey) (*domain.PageQuery, error) {
+	query := &domain.PageQuery{
+		Sort:         sort,
+		Limit:        limit,
+		IncludeTotal: includeTotal,
+	}
+	if query.Limit == 0 {
+		query.Limit = domain.PageDefaultLimit
+	}
+	if query.Limit > domain.PageMaxLimit {
+		query.Limit = domain.PageMaxLimit
+	}
+
+	if cursor == "" {
+		return query, nil
+	}
+
+	invalid := domain.NewValidationError([]*domain.FieldError{{Field: "cursor", Tag: "invalid"}})
+	raw, errDec := base64.RawURLEncoding.DecodeString(cursor)
+	if errDec != nil {
+		return nil, invalid
+	}
+	decoded := &pageCursor{}
+	errJson := json.Unmarshal(raw, decoded)
+	if errJson != nil || decoded.Sort != sortString(sort) || len(decoded.Values) != len(sort) {
+		return nil, invalid
+	}
+
+	query.After = decoded.Values
+	query.Backward = decoded.Backward
+	return query, nil
+}
+
+// pageCursors returns the cursors of the pages next to items. values gives
+// the sort values of an item in query.Sort order.
+func pageCursors[T any](query *domain.PageQuery, items []T, hasMore bool, values func(item T) []string) (*string, *string) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+	first := values(items[0])
+	last := values(items[len(items)-1])
+
+	// halaman yang dibaca mundur selalu punya halaman sesudahnya
+	var next, prev *string
+	if query.Backward {
+		next = encodeCursor(query.Sort, last, false)
+		if hasMore {
+			prev = encodeCursor(query.Sort, first, true)
+		}
+		return next, prev
+	}
+
+	if hasMore {
+		next = encodeCursor(query.Sort, last, false)
+	}
+	if query.After != nil {
+		prev = encodeCursor(query.Sort, first, true)
+	}
+	return next, prev
+}
+
+// sortString writes keys the way the sort query option takes them, e.g.
+// "-name,id".
+func sortString(keys []domain.SortKey) string {
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key.Desc {
+			parts = append(parts, "-"+key.Field)
+		} else {
+			parts = append(parts, key.Field)
+		}
+	}
+	return strings.Join(parts, ",")
+}
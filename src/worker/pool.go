@@ -0,0 +1,73 @@
+package worker
+
+import (
+	"context"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Pool bounds how many handlers run at once across every job that uses it,
+// so concurrent uploads share the same slots instead of each starting their
+// own unbounded set of goroutines.
+type Pool struct {
+	slots   chan struct{}
+	Workers int
+	Buffer  int
+}
+
+func NewPool(maxConcurrency int, workers int, buffer int) *Pool {
+	if maxConcurrency < 1 {
+		maxConcurrency = 1
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	if buffer < 0 {
+		buffer = 0
+	}
+
+	return &Pool{
+		slots:   make(chan struct{}, maxConcurrency),
+		Workers: workers,
+		Buffer:  buffer,
+	}
+}
+
+// Run starts Workers goroutines for a single job. produce sends items with
+// the given send func and handle is called once per item while holding one
+// of the pool's slots. Run returns after every item has been handled, or with
+// the first error from produce or ctx.
+func Run[T any](ctx context.Context, p *Pool, produce func(send func(T) error) error, handle func(ctx context.Context, worker int, item T)) error {
+	g, ctx := errgroup.WithContext(ctx)
+	items := make(chan T, p.Buffer)
+
+	g.Go(func() error {
+		defer close(items)
+		return produce(func(item T) error {
+			select {
+			case items <- item:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	})
+
+	for workerIndex := 0; workerIndex < p.Workers; workerIndex++ {
+		workerIndex := workerIndex
+		g.Go(func() error {
+			for item := range items {
+				select {
+				case p.slots <- struct{}{}:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				handle(ctx, workerIndex, item)
+				<-p.slots
+			}
+			return nil
+		})
+	}
+
+	return g.Wait()
+}